@@ -0,0 +1,285 @@
+// Package validator implements value validations
+//
+// Copyright 2014 Roberto Teixeira <robteix@robteix.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validator
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/validator.v2/internal/tagsyntax"
+)
+
+// Rule is a rule of a field given to Field, as written in a tag.
+type Rule struct {
+	name  string
+	param string
+	// keys and elems are the rules following dive, set for Dive
+	// and DiveMap.
+	keys  []Rule
+	elems []Rule
+	// message and code are given with WithMessage and WithCode.
+	message string
+	code    string
+}
+
+// WithMessage returns r with the message of its errors, as given
+// in a tag with msg.
+func (r Rule) WithMessage(msg string) Rule {
+	r.message = msg
+	return r
+}
+
+// WithCode returns r with the code of its errors, as given in a tag
+// with code.
+func (r Rule) WithCode(code string) Rule {
+	r.code = code
+	return r
+}
+
+// String returns the rule as written in a tag.
+func (r Rule) String() string {
+	return joinRules([]Rule{r})
+}
+
+// joinRules returns the tag holding rules. The rules of a dive are
+// written last, as those following dive apply to the elements.
+func joinRules(rules []Rule) string {
+	items := make([]string, 0, len(rules))
+	var dive *Rule
+	for i := range rules {
+		switch r := &rules[i]; {
+		case r.name == diveTag:
+			dive = r
+		case r.param == "":
+			items = append(items, r.name)
+		default:
+			items = append(items, r.name+"="+escapeParam(r.param))
+		}
+		if r := &rules[i]; r.name != diveTag {
+			if r.message != "" {
+				items = append(items, tagsyntax.Msg+"="+escapeParam(r.message))
+			}
+			if r.code != "" {
+				items = append(items, tagsyntax.Code+"="+escapeParam(r.code))
+			}
+		}
+	}
+	if dive != nil {
+		items = append(items, diveTag)
+		if dive.keys != nil {
+			items = append(items, tagsyntax.Keys, joinRules(dive.keys), tagsyntax.EndKeys)
+		}
+		if len(dive.elems) > 0 {
+			items = append(items, joinRules(dive.elems))
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// escapeParam escapes the commas of a parameter.
+func escapeParam(param string) string {
+	return strings.Replace(param, ",", `\,`, -1)
+}
+
+// number is the constraint of the parameters of Min, Max and Len.
+type number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// NonZero returns the nonzero rule.
+func NonZero() Rule { return Rule{name: "nonzero"} }
+
+// NonNil returns the nonnil rule.
+func NonNil() Rule { return Rule{name: "nonnil"} }
+
+// OmitEmpty returns the omitempty rule, skipping the rules following it
+// for empty values.
+func OmitEmpty() Rule { return Rule{name: omitEmptyTag} }
+
+// Min returns the min rule.
+func Min[N number](n N) Rule { return Rule{name: "min", param: fmt.Sprint(n)} }
+
+// Max returns the max rule.
+func Max[N number](n N) Rule { return Rule{name: "max", param: fmt.Sprint(n)} }
+
+// Len returns the len rule.
+func Len[N number](n N) Rule { return Rule{name: "len", param: fmt.Sprint(n)} }
+
+// Regexp returns the regexp rule.
+func Regexp(expr string) Rule { return Rule{name: "regexp", param: expr} }
+
+// Named returns the rule of the given name and parameter, such as one
+// set with SetValidationFunc.
+func Named(name, param string) Rule { return Rule{name: name, param: param} }
+
+// Dive returns the rules applied to each element of a slice or array,
+// or to each value of a map.
+func Dive(elems ...Rule) Rule { return Rule{name: diveTag, elems: elems} }
+
+// DiveMap returns the rules applied to each key and each value of a map.
+func DiveMap(keys []Rule, values ...Rule) Rule {
+	if keys == nil {
+		keys = []Rule{}
+	}
+	return Rule{name: diveTag, keys: keys, elems: values}
+}
+
+// TypeRules holds the rules of the fields of struct type T, built with
+// Field and set with Register.
+type TypeRules[T any] struct {
+	rules map[string]string
+	err   error
+}
+
+// Rules returns the rules of the fields of struct type T, to be given
+// with Field:
+//
+//	tr := validator.Rules[User]()
+//	validator.Field(tr, func(u *User) *string { return &u.Name }, validator.NonZero(), validator.Max(40))
+//	validator.Field(tr, func(u *User) *string { return &u.Address.Zip }, validator.Len(5))
+//	err := tr.Register()
+//
+// Fields are selected by functions returning their address, so they are
+// checked by the compiler. The rules are written as tags and registered
+// with RegisterRules, they are thus validated and reported as tags are.
+func Rules[T any]() *TypeRules[T] {
+	return &TypeRules[T]{rules: make(map[string]string)}
+}
+
+// Field sets the rules in tr of the field of T whose address is returned
+// by sel, and returns tr. The field may be in nested structs, including
+// those pointers refer to, but not in the elements of collections.
+func Field[T, F any](tr *TypeRules[T], sel func(*T) *F, rules ...Rule) *TypeRules[T] {
+	if tr.err != nil {
+		return tr
+	}
+	path, err := selectedField(sel)
+	if err != nil {
+		tr.err = err
+		return tr
+	}
+	var dives int
+	for _, r := range rules {
+		if r.name == diveTag {
+			dives++
+		}
+	}
+	if dives > 1 {
+		tr.err = fmt.Errorf("%w: more than one dive for %s", ErrBadParameter, path)
+		return tr
+	}
+	tr.rules[path] = joinRules(rules)
+	return tr
+}
+
+// Tags returns the rules given so far, written as tags and indexed by
+// the path of their field, as given to RegisterRules.
+func (tr *TypeRules[T]) Tags() (map[string]string, error) {
+	if tr.err != nil {
+		return nil, tr.err
+	}
+	m := make(map[string]string, len(tr.rules))
+	for k, v := range tr.rules {
+		m[k] = v
+	}
+	return m, nil
+}
+
+// Register registers the rules on the default validator.
+func (tr *TypeRules[T]) Register() error {
+	return tr.RegisterWith(defaultValidator)
+}
+
+// RegisterWith registers the rules on mv with RegisterRules, reporting
+// the first error found by Field, if any.
+func (tr *TypeRules[T]) RegisterWith(mv *Validator) error {
+	if tr.err != nil {
+		return tr.err
+	}
+	return mv.RegisterRules(reflect.TypeOf((*T)(nil)).Elem(), tr.rules)
+}
+
+// selectedField returns the dotted path of the field of struct type T
+// whose address is returned by sel, called on a value of type T whose
+// pointers to structs are set.
+func selectedField[T, F any](sel func(*T) *F) (path string, err error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	ft := reflect.TypeOf(sel)
+	if t.Kind() != reflect.Struct || sel == nil {
+		return "", fmt.Errorf("%w: selector %s for %s, want a struct field", ErrBadParameter, ft, t)
+	}
+	v := reflect.New(t)
+	allocStructs(v.Elem(), map[reflect.Type]bool{t: true})
+	defer func() {
+		if recover() != nil {
+			path, err = "", fmt.Errorf("%w: selector %s of %s panicked", ErrUnknownField, ft, t)
+		}
+	}()
+	p := sel(v.Interface().(*T))
+	if p == nil {
+		return "", fmt.Errorf("%w: selector %s of %s returned nil", ErrUnknownField, ft, t)
+	}
+	if path, found := findField(v.Elem(), reflect.ValueOf(p).Pointer(), reflect.TypeOf(p).Elem(), ""); found {
+		return path, nil
+	}
+	return "", fmt.Errorf("%w: selector %s of %s returned no field", ErrUnknownField, ft, t)
+}
+
+// allocStructs sets the nil pointers to structs found in struct v, so
+// selectors can go through them. Types already on the path are left nil.
+func allocStructs(v reflect.Value, seen map[reflect.Type]bool) {
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		if !f.CanSet() {
+			continue
+		}
+		switch {
+		case f.Kind() == reflect.Struct:
+			allocStructs(f, seen)
+		case f.Kind() == reflect.Ptr && f.Type().Elem().Kind() == reflect.Struct && !seen[f.Type().Elem()]:
+			f.Set(reflect.New(f.Type().Elem()))
+			seen[f.Type().Elem()] = true
+			allocStructs(f.Elem(), seen)
+			delete(seen, f.Type().Elem())
+		}
+	}
+}
+
+// findField returns the path of the field of struct v at address addr
+// and of type t. A struct and its first field share their address, so
+// the type tells which of them is meant.
+func findField(v reflect.Value, addr uintptr, t reflect.Type, path string) (string, bool) {
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		name := joinPath(path, v.Type().Field(i).Name)
+		if f.CanAddr() && f.UnsafeAddr() == addr && f.Type() == t {
+			return name, true
+		}
+		if f.Kind() == reflect.Ptr && !f.IsNil() {
+			f = f.Elem()
+		}
+		if f.Kind() == reflect.Struct {
+			if p, found := findField(f, addr, t, name); found {
+				return p, true
+			}
+		}
+	}
+	return "", false
+}
@@ -17,9 +17,14 @@
 package validator
 
 import (
+	"container/list"
+	"fmt"
 	"reflect"
 	"regexp"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 	"unicode/utf8"
 )
 
@@ -229,7 +234,7 @@ func regex(v interface{}, param string) error {
 		return ErrUnsupported
 	}
 	s := rv.String()
-	re, err := regexp.Compile(param)
+	re, err := compileRegexp(param)
 	if err != nil {
 		return ErrBadParameter
 	}
@@ -240,6 +245,63 @@ func regex(v interface{}, param string) error {
 	return nil
 }
 
+// maxRegexps is the number of compiled regular expressions kept by
+// compileRegexp.
+const maxRegexps = 256
+
+// regexpCache holds the regular expressions compiled last, indexed by
+// their source, so that those of tags are compiled once while patterns
+// given to Valid or read from schemas do not pile up.
+type regexpCache struct {
+	mu     sync.Mutex
+	byExpr map[string]*list.Element // values are *regexp.Regexp
+	lru    list.List                // most recently used first
+}
+
+// regexps caches the regular expressions compiled by compileRegexp.
+var regexps = &regexpCache{byExpr: make(map[string]*list.Element)}
+
+// compileRegexp compiles the regular expression expr, reusing a
+// previous compilation of the same expression when possible.
+func compileRegexp(expr string) (*regexp.Regexp, error) {
+	if re := regexps.get(expr); re != nil {
+		return re, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	regexps.add(re)
+	return re, nil
+}
+
+// get returns the regular expression compiled from expr, if cached.
+func (c *regexpCache) get(expr string) *regexp.Regexp {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.byExpr[expr]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(e)
+	return e.Value.(*regexp.Regexp)
+}
+
+// add caches re, dropping the least recently used expression past
+// maxRegexps.
+func (c *regexpCache) add(re *regexp.Regexp) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.byExpr[re.String()]; ok {
+		return
+	}
+	c.byExpr[re.String()] = c.lru.PushFront(re)
+	if c.lru.Len() > maxRegexps {
+		oldest := c.lru.Remove(c.lru.Back()).(*regexp.Regexp)
+		delete(c.byExpr, oldest.String())
+	}
+}
+
 // asInt returns the parameter as a int64
 // or panics if it can't convert
 func asInt(param string) (int64, error) {
@@ -287,3 +349,269 @@ func nonnil(v interface{}, param string) error {
 	}
 	return nil
 }
+
+// eqfield tests whether a variable is equal to the value of the
+// field named by param.
+func eqfield(v interface{}, param string, fc fieldContext) error {
+	return compareField(v, param, fc, false, ErrEqField, func(c int) bool { return c == 0 })
+}
+
+// nefield tests whether a variable is different from the value
+// of the field named by param.
+func nefield(v interface{}, param string, fc fieldContext) error {
+	return compareField(v, param, fc, false, ErrNeField, func(c int) bool { return c != 0 })
+}
+
+// gtfield tests whether a variable is greater than the value of
+// the field named by param.
+func gtfield(v interface{}, param string, fc fieldContext) error {
+	return compareField(v, param, fc, true, ErrGtField, func(c int) bool { return c > 0 })
+}
+
+// gtefield tests whether a variable is greater than or equal to
+// the value of the field named by param.
+func gtefield(v interface{}, param string, fc fieldContext) error {
+	return compareField(v, param, fc, true, ErrGteField, func(c int) bool { return c >= 0 })
+}
+
+// ltfield tests whether a variable is less than the value of
+// the field named by param.
+func ltfield(v interface{}, param string, fc fieldContext) error {
+	return compareField(v, param, fc, true, ErrLtField, func(c int) bool { return c < 0 })
+}
+
+// ltefield tests whether a variable is less than or equal to
+// the value of the field named by param.
+func ltefield(v interface{}, param string, fc fieldContext) error {
+	return compareField(v, param, fc, true, ErrLteField, func(c int) bool { return c <= 0 })
+}
+
+// compareField compares a variable with the field named by param and
+// returns fail unless valid accepts the result of the comparison.
+// As with the other builtins, nil pointers on either side are valid.
+func compareField(v interface{}, param string, fc fieldContext, ordered bool, fail error, valid func(int) bool) error {
+	other, found := lookupField(fc, param)
+	if !found {
+		return ErrBadParameter
+	}
+	a, b := indirect(reflect.ValueOf(v)), indirect(other)
+	if isNilOrInvalid(a) || isNilOrInvalid(b) {
+		return nil
+	}
+	c, err := compareValues(a, b, ordered)
+	if err != nil {
+		return err
+	}
+	if !valid(c) {
+		return fail
+	}
+	return nil
+}
+
+func isNilOrInvalid(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// compareValues returns -1, 0 or 1 depending on whether a is less
+// than, equal to or greater than b. Only values of the same kind can
+// be compared; when ordered is false, bools are compared too but any
+// difference between them is reported as 1.
+func compareValues(a, b reflect.Value, ordered bool) (int, error) {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch b.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return compareOrdered(a.Int() < b.Int(), a.Int() > b.Int()), nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		switch b.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return compareOrdered(a.Uint() < b.Uint(), a.Uint() > b.Uint()), nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch b.Kind() {
+		case reflect.Float32, reflect.Float64:
+			return compareOrdered(a.Float() < b.Float(), a.Float() > b.Float()), nil
+		}
+	case reflect.String:
+		if b.Kind() == reflect.String {
+			return compareOrdered(a.String() < b.String(), a.String() > b.String()), nil
+		}
+	case reflect.Bool:
+		if b.Kind() == reflect.Bool && !ordered {
+			return compareOrdered(false, a.Bool() != b.Bool()), nil
+		}
+	case reflect.Struct:
+		if a.Type() == timeType && b.Type() == timeType && a.CanInterface() && b.CanInterface() {
+			ta, tb := a.Interface().(time.Time), b.Interface().(time.Time)
+			return compareOrdered(ta.Before(tb), ta.After(tb)), nil
+		}
+	}
+	return 0, ErrUnsupported
+}
+
+func compareOrdered(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
+
+// lookupField returns the value of the field named by path, which is
+// looked up first in the struct holding the field being validated and
+// then from the root value. A path made of dotted names refers to
+// nested fields. If a nil pointer is found along the path, the
+// returned value is invalid but found is still true.
+func lookupField(fc fieldContext, path string) (v reflect.Value, found bool) {
+	if v, found = fieldByPath(fc.parent, path); found {
+		return v, true
+	}
+	return fieldByPath(fc.root, path)
+}
+
+func fieldByPath(v reflect.Value, path string) (reflect.Value, bool) {
+	if path == "" {
+		return reflect.Value{}, false
+	}
+	for _, name := range strings.Split(path, ".") {
+		v = indirect(v)
+		if isNilOrInvalid(v) && v.IsValid() {
+			// a nil pointer along the way, nothing to compare with
+			return reflect.Value{}, true
+		}
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, false
+		}
+		f, ok := v.Type().FieldByName(name)
+		if !ok || f.PkgPath != "" {
+			return reflect.Value{}, false
+		}
+		fv, err := v.FieldByIndexErr(f.Index)
+		if err != nil {
+			// nil embedded pointer
+			return reflect.Value{}, true
+		}
+		v = fv
+	}
+	return v, true
+}
+
+// requiredIf tests whether a variable is nonzero when the fields given
+// as parameter have the given values. The parameter is a space
+// separated list of field names and values, all of which must match
+// (e.g. "AccountType business").
+func requiredIf(v interface{}, param string, fc fieldContext) error {
+	match, err := fieldsMatch(param, fc)
+	if err != nil || !match {
+		return err
+	}
+	return required(v, ErrRequiredIf)
+}
+
+// requiredUnless tests whether a variable is nonzero unless the fields
+// given as parameter have the given values, as for required_if.
+func requiredUnless(v interface{}, param string, fc fieldContext) error {
+	match, err := fieldsMatch(param, fc)
+	if err != nil || match {
+		return err
+	}
+	return required(v, ErrRequiredUnless)
+}
+
+// requiredWith tests whether a variable is nonzero when any of the
+// space separated fields given as parameter is nonzero.
+func requiredWith(v interface{}, param string, fc fieldContext) error {
+	names := strings.Fields(param)
+	if len(names) == 0 {
+		return ErrBadParameter
+	}
+	for _, name := range names {
+		present, err := fieldPresent(name, fc)
+		if err != nil {
+			return err
+		}
+		if present {
+			return required(v, ErrRequiredWith)
+		}
+	}
+	return nil
+}
+
+// requiredWithout tests whether a variable is nonzero when any of the
+// space separated fields given as parameter is zero.
+func requiredWithout(v interface{}, param string, fc fieldContext) error {
+	names := strings.Fields(param)
+	if len(names) == 0 {
+		return ErrBadParameter
+	}
+	for _, name := range names {
+		present, err := fieldPresent(name, fc)
+		if err != nil {
+			return err
+		}
+		if !present {
+			return required(v, ErrRequiredWithout)
+		}
+	}
+	return nil
+}
+
+// required applies the nonzero rule to v, reporting a zero
+// value with the given error instead of ErrZeroValue.
+func required(v interface{}, zero error) error {
+	if err := nonzero(v, ""); err != nil {
+		if err == ErrZeroValue {
+			return zero
+		}
+		return err
+	}
+	return nil
+}
+
+// fieldsMatch reports whether every field named in a "Field value"
+// list has the value following its name. Values are compared with
+// the default formatting of the field value.
+func fieldsMatch(param string, fc fieldContext) (bool, error) {
+	pairs := strings.Fields(param)
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return false, ErrBadParameter
+	}
+	for i := 0; i < len(pairs); i += 2 {
+		other, found := lookupField(fc, pairs[i])
+		if !found {
+			return false, ErrBadParameter
+		}
+		other = indirect(other)
+		if isNilOrInvalid(other) || fmt.Sprint(valueInterface(other)) != pairs[i+1] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// fieldPresent reports whether the named field is nonzero as
+// defined by the nonzero rule.
+func fieldPresent(name string, fc fieldContext) (bool, error) {
+	other, found := lookupField(fc, name)
+	if !found {
+		return false, ErrBadParameter
+	}
+	if err := nonzero(valueInterface(indirect(other)), ""); err != nil {
+		if err == ErrZeroValue {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
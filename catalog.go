@@ -0,0 +1,191 @@
+// Package validator implements value validations
+//
+// Copyright 2014 Roberto Teixeira <robteix@robteix.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validator
+
+// defaultCatalog holds the messages of the errors of the builtin rules,
+// see DefaultCatalog.
+var defaultCatalog = Catalog{
+	"en": {
+		"value": "value",
+		"or":    " or ",
+
+		"nonzero":          "{label} is required",
+		"nonnil":           "{label} is required",
+		"len":              "{label} must be {param}",
+		"len.chars.one":    "{label} must be {param} character long",
+		"len.chars":        "{label} must be {param} characters long",
+		"len.items.one":    "{label} must contain {param} item",
+		"len.items":        "{label} must contain {param} items",
+		"min":              "{label} must be at least {param}",
+		"min.chars.one":    "{label} must be at least {param} character long",
+		"min.chars":        "{label} must be at least {param} characters long",
+		"min.items.one":    "{label} must contain at least {param} item",
+		"min.items":        "{label} must contain at least {param} items",
+		"max":              "{label} must be at most {param}",
+		"max.chars.one":    "{label} must be at most {param} character long",
+		"max.chars":        "{label} must be at most {param} characters long",
+		"max.items.one":    "{label} must contain at most {param} item",
+		"max.items":        "{label} must contain at most {param} items",
+		"regexp":           "{label} has an invalid format",
+		"eqfield":          "{label} must be equal to {param}",
+		"nefield":          "{label} must differ from {param}",
+		"gtfield":          "{label} must be greater than {param}",
+		"gtefield":         "{label} must be greater than or equal to {param}",
+		"ltfield":          "{label} must be less than {param}",
+		"ltefield":         "{label} must be less than or equal to {param}",
+		"required_if":      "{label} is required",
+		"required_unless":  "{label} is required",
+		"required_with":    "{label} is required",
+		"required_without": "{label} is required",
+
+		"unsupported":    "{label} cannot be checked with {rule}",
+		"badparameter":   "{label} has a rule with an invalid parameter: {rule}",
+		"unknowntag":     "{label} has an unknown rule: {rule}",
+		"invalid":        "{label} is invalid",
+		"cannotvalidate": "{label} cannot be validated",
+		"cycle":          "{label} refers back to itself",
+		"maxdepth":       "{label} is nested too deeply",
+		"type":           "{label} has the wrong type",
+		"not":            "{label} is not allowed",
+	},
+	"nl": {
+		"value": "waarde",
+		"or":    " of ",
+
+		"nonzero":          "{label} is verplicht",
+		"nonnil":           "{label} is verplicht",
+		"len":              "{label} moet {param} zijn",
+		"len.chars.one":    "{label} moet {param} teken lang zijn",
+		"len.chars":        "{label} moet {param} tekens lang zijn",
+		"len.items.one":    "{label} moet {param} element bevatten",
+		"len.items":        "{label} moet {param} elementen bevatten",
+		"min":              "{label} moet minstens {param} zijn",
+		"min.chars.one":    "{label} moet minstens {param} teken lang zijn",
+		"min.chars":        "{label} moet minstens {param} tekens lang zijn",
+		"min.items.one":    "{label} moet minstens {param} element bevatten",
+		"min.items":        "{label} moet minstens {param} elementen bevatten",
+		"max":              "{label} mag hoogstens {param} zijn",
+		"max.chars.one":    "{label} mag hoogstens {param} teken lang zijn",
+		"max.chars":        "{label} mag hoogstens {param} tekens lang zijn",
+		"max.items.one":    "{label} mag hoogstens {param} element bevatten",
+		"max.items":        "{label} mag hoogstens {param} elementen bevatten",
+		"regexp":           "{label} heeft een ongeldig formaat",
+		"eqfield":          "{label} moet gelijk zijn aan {param}",
+		"nefield":          "{label} moet verschillen van {param}",
+		"gtfield":          "{label} moet groter zijn dan {param}",
+		"gtefield":         "{label} moet groter dan of gelijk aan {param} zijn",
+		"ltfield":          "{label} moet kleiner zijn dan {param}",
+		"ltefield":         "{label} moet kleiner dan of gelijk aan {param} zijn",
+		"required_if":      "{label} is verplicht",
+		"required_unless":  "{label} is verplicht",
+		"required_with":    "{label} is verplicht",
+		"required_without": "{label} is verplicht",
+
+		"unsupported":    "{label} kan niet gecontroleerd worden met {rule}",
+		"badparameter":   "{label} heeft een regel met een ongeldige parameter: {rule}",
+		"unknowntag":     "{label} heeft een onbekende regel: {rule}",
+		"invalid":        "{label} is ongeldig",
+		"cannotvalidate": "{label} kan niet gevalideerd worden",
+		"cycle":          "{label} verwijst naar zichzelf",
+		"maxdepth":       "{label} is te diep genest",
+		"type":           "{label} heeft het verkeerde type",
+		"not":            "{label} is niet toegestaan",
+	},
+	"de": {
+		"value": "Wert",
+		"or":    " oder ",
+
+		"nonzero":          "{label} ist erforderlich",
+		"nonnil":           "{label} ist erforderlich",
+		"len":              "{label} muss {param} sein",
+		"len.chars":        "{label} muss {param} Zeichen lang sein",
+		"len.items.one":    "{label} muss {param} Element enthalten",
+		"len.items":        "{label} muss {param} Elemente enthalten",
+		"min":              "{label} muss mindestens {param} sein",
+		"min.chars":        "{label} muss mindestens {param} Zeichen lang sein",
+		"min.items.one":    "{label} muss mindestens {param} Element enthalten",
+		"min.items":        "{label} muss mindestens {param} Elemente enthalten",
+		"max":              "{label} darf höchstens {param} sein",
+		"max.chars":        "{label} darf höchstens {param} Zeichen lang sein",
+		"max.items.one":    "{label} darf höchstens {param} Element enthalten",
+		"max.items":        "{label} darf höchstens {param} Elemente enthalten",
+		"regexp":           "{label} hat ein ungültiges Format",
+		"eqfield":          "{label} muss gleich {param} sein",
+		"nefield":          "{label} muss sich von {param} unterscheiden",
+		"gtfield":          "{label} muss größer als {param} sein",
+		"gtefield":         "{label} muss größer als oder gleich {param} sein",
+		"ltfield":          "{label} muss kleiner als {param} sein",
+		"ltefield":         "{label} muss kleiner als oder gleich {param} sein",
+		"required_if":      "{label} ist erforderlich",
+		"required_unless":  "{label} ist erforderlich",
+		"required_with":    "{label} ist erforderlich",
+		"required_without": "{label} ist erforderlich",
+
+		"unsupported":    "{label} kann nicht mit {rule} geprüft werden",
+		"badparameter":   "{label} hat eine Regel mit ungültigem Parameter: {rule}",
+		"unknowntag":     "{label} hat eine unbekannte Regel: {rule}",
+		"invalid":        "{label} ist ungültig",
+		"cannotvalidate": "{label} kann nicht validiert werden",
+		"cycle":          "{label} verweist auf sich selbst",
+		"maxdepth":       "{label} ist zu tief verschachtelt",
+		"type":           "{label} hat den falschen Typ",
+		"not":            "{label} ist nicht erlaubt",
+	},
+	"fr": {
+		"value": "valeur",
+		"or":    " ou ",
+
+		"nonzero":          "{label} est obligatoire",
+		"nonnil":           "{label} est obligatoire",
+		"len":              "{label} doit être égal à {param}",
+		"len.chars.one":    "{label} doit contenir {param} caractère",
+		"len.chars":        "{label} doit contenir {param} caractères",
+		"len.items.one":    "{label} doit contenir {param} élément",
+		"len.items":        "{label} doit contenir {param} éléments",
+		"min":              "{label} doit être supérieur ou égal à {param}",
+		"min.chars.one":    "{label} doit contenir au moins {param} caractère",
+		"min.chars":        "{label} doit contenir au moins {param} caractères",
+		"min.items.one":    "{label} doit contenir au moins {param} élément",
+		"min.items":        "{label} doit contenir au moins {param} éléments",
+		"max":              "{label} doit être inférieur ou égal à {param}",
+		"max.chars.one":    "{label} doit contenir au plus {param} caractère",
+		"max.chars":        "{label} doit contenir au plus {param} caractères",
+		"max.items.one":    "{label} doit contenir au plus {param} élément",
+		"max.items":        "{label} doit contenir au plus {param} éléments",
+		"regexp":           "{label} a un format invalide",
+		"eqfield":          "{label} doit être égal à {param}",
+		"nefield":          "{label} doit être différent de {param}",
+		"gtfield":          "{label} doit être supérieur à {param}",
+		"gtefield":         "{label} doit être supérieur ou égal à {param}",
+		"ltfield":          "{label} doit être inférieur à {param}",
+		"ltefield":         "{label} doit être inférieur ou égal à {param}",
+		"required_if":      "{label} est obligatoire",
+		"required_unless":  "{label} est obligatoire",
+		"required_with":    "{label} est obligatoire",
+		"required_without": "{label} est obligatoire",
+
+		"unsupported":    "{label} ne peut pas être vérifié avec {rule}",
+		"badparameter":   "{label} a une règle avec un paramètre invalide : {rule}",
+		"unknowntag":     "{label} a une règle inconnue : {rule}",
+		"invalid":        "{label} est invalide",
+		"cannotvalidate": "{label} ne peut pas être validé",
+		"cycle":          "{label} fait référence à lui-même",
+		"maxdepth":       "{label} est imbriqué trop profondément",
+		"type":           "{label} a un type incorrect",
+		"not":            "{label} n'est pas autorisé",
+	},
+}
@@ -0,0 +1,291 @@
+// Package validator implements value validations
+//
+// Copyright 2014 Roberto Teixeira <robteix@robteix.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/validator.v2/internal/tagsyntax"
+)
+
+// checkFunc checks, without any value at hand, that a rule can be
+// applied to fields of type t with the given parameter. It returns
+// ErrBadParameter or ErrUnsupported when it cannot.
+type checkFunc func(t reflect.Type, param string, cc checkContext) error
+
+// checkContext gives check funcs access to the types surrounding
+// the field being checked.
+type checkContext struct {
+	// parent is the struct type holding the field.
+	parent reflect.Type
+	// root is the type given to Check.
+	root reflect.Type
+	// scopes are the struct types being walked that have rules
+	// registered through nested paths, outermost first.
+	scopes []reflect.Type
+}
+
+// Check calls the Check method on the default validator.
+func Check(typ interface{}) error {
+	return defaultValidator.Check(typ)
+}
+
+// MustCheck calls the MustCheck method on the default validator.
+func MustCheck(typ interface{}) {
+	defaultValidator.MustCheck(typ)
+}
+
+// Check reports the mistakes in the tags of a type without validating any
+// value, so they can be found before values of the type are validated.
+// typ is either a reflect.Type or a value of the type, such as a nil
+// pointer to it. The fields of structs are checked recursively, following
+// embedded structs, pointers and the elements of arrays, slices and maps.
+//
+// Unknown rules, parameters the rules cannot parse, invalid regular
+// expressions, rules applied to fields of a kind they do not support and
+// builtin rules that cannot be satisfied together, as found by Conflicts,
+// are reported in an ErrorMap indexed by the path of the field, as
+// *TagErrors wrapping ErrUnknownTag, ErrBadParameter, ErrUnsupported or
+// ErrConflict. The errors of parameters holding alternatives left out of
+// parentheses, as in min=3|len=2, tell to add them.
+// Functions set with SetValidationFunc or SetValidationFuncContext cannot
+// be checked beyond their name.
+func (mv *Validator) Check(typ interface{}) error {
+	if m := mv.check(typ); len(m) > 0 {
+		return m
+	}
+	return nil
+}
+
+// MustCheck is like Check but panics if the tags of the type have
+// mistakes. It is meant to be called from init functions.
+func (mv *Validator) MustCheck(typ interface{}) {
+	if err := mv.Check(typ); err != nil {
+		panic("validator: " + err.Error())
+	}
+}
+
+// check returns the mistakes found by Check in the tags of typ.
+func (mv *Validator) check(typ interface{}) ErrorMap {
+	m := make(ErrorMap)
+	t, ok := typ.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(typ)
+	}
+	if t == nil {
+		return m
+	}
+	cfg := mv.config()
+	cfg.checkType(t, checkContext{root: indirectType(t)}, m, "", make(map[planKey]bool))
+	return m
+}
+
+// checkType checks the struct types found in type t, as
+// deepValidateCollection would walk a value of type t. Each struct
+// type is checked once for each scope of the registered rules.
+func (cfg *config) checkType(t reflect.Type, cc checkContext, m ErrorMap, path string, seen map[planKey]bool) {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Array, reflect.Slice:
+		if t.Kind() != reflect.Ptr {
+			path += "[]"
+		}
+		cfg.checkType(t.Elem(), cc, m, path, seen)
+	case reflect.Map:
+		cfg.checkType(t.Key(), cc, m, path+"[](key)", seen)
+		cfg.checkType(t.Elem(), cc, m, path+"[](value)", seen)
+	case reflect.Struct:
+		k := planKey{t, cfg.ruleScope(cc.scopes, t)}
+		if seen[k] {
+			return
+		}
+		seen[k] = true
+		cc.parent = t
+		cc.scopes = cfg.enterScope(cc.scopes, t)
+		p := cfg.structPlan(k.st, k.scope)
+		for i := range p.fields {
+			fp := &p.fields[i]
+			fn := joinPath(path, fp.name)
+			ft := t.Field(fp.index).Type
+			if fp.err != nil {
+				m[fn] = append(m[fn], fp.err)
+			} else if fp.rules != nil {
+				for _, err := range cfg.checkRules(fp.rules, ft, cc) {
+					err.Type, err.Field = t, fp.field
+					m[fn] = append(m[fn], err)
+				}
+			}
+			if fp.recurse {
+				cfg.checkType(ft, cc, m, fn, seen)
+			}
+		}
+	}
+}
+
+// checkRules checks the rules of rs against fields of type t.
+func (cfg *config) checkRules(rs *ruleSet, t reflect.Type, cc checkContext) []*TagError {
+	t = indirectType(t)
+	if t.Kind() == reflect.Interface {
+		// the rules apply to the dynamic value
+		return nil
+	}
+	var errs []*TagError
+	for i := range rs.tags {
+		tags := []*tag{&rs.tags[i]}
+		if rs.tags[i].Expr != nil {
+			tags = rs.tags[i].Expr.leaves()
+		}
+		for _, tg := range tags {
+			check, found := cfg.checkFuncs[tg.Name]
+			if !found {
+				continue
+			}
+			if err := check(t, tg.Param, cc); err != nil {
+				offset := tg.Offset
+				if errors.Is(err, ErrBadParameter) {
+					offset = tg.ParamOffset
+					if tg.Name != "regexp" && tagsyntax.BareAlternatives(tg.Param) {
+						err = fmt.Errorf("%w, alternatives need parentheses, as in (%s=%s)", err, tg.Name, tg.Param)
+					}
+				}
+				errs = append(errs, &TagError{Tag: rs.src, Rule: tg.Name, Offset: offset, Err: err})
+			}
+		}
+	}
+	if err := cfg.checkConflicts(rs, t); err != nil {
+		errs = append(errs, err)
+	}
+	if rs.elems == nil && rs.keys == nil {
+		return errs
+	}
+	switch t.Kind() {
+	case reflect.Array, reflect.Slice:
+		if rs.elems != nil {
+			errs = append(errs, cfg.checkRules(rs.elems, t.Elem(), cc)...)
+		}
+	case reflect.Map:
+		if rs.keys != nil {
+			errs = append(errs, cfg.checkRules(rs.keys, t.Key(), cc)...)
+		}
+		if rs.elems != nil {
+			errs = append(errs, cfg.checkRules(rs.elems, t.Elem(), cc)...)
+		}
+	default:
+		errs = append(errs, &TagError{Tag: rs.src, Rule: diveTag, Offset: rs.dive, Err: ErrUnsupported})
+	}
+	return errs
+}
+
+// indirectType dereferences pointer types, as indirect does for values.
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// probe returns a check func that runs fn against the zero value of the
+// type checked, keeping only the errors telling the rule cannot be used.
+// It is only meant for the builtin rules, which have no side effects.
+func probe(fn ValidationFunc) checkFunc {
+	return func(t reflect.Type, param string, _ checkContext) error {
+		err := fn(reflect.Zero(t).Interface(), param)
+		if err == ErrBadParameter || err == ErrUnsupported {
+			return err
+		}
+		return nil
+	}
+}
+
+// checkCompareField checks the parameter of the rules comparing a
+// field with another one.
+func checkCompareField(ordered bool) checkFunc {
+	return func(t reflect.Type, param string, cc checkContext) error {
+		other, found := lookupFieldType(cc, param)
+		if !found {
+			return ErrBadParameter
+		}
+		if other.Kind() == reflect.Interface {
+			return nil
+		}
+		_, err := compareValues(reflect.Zero(t), reflect.Zero(other), ordered)
+		return err
+	}
+}
+
+// checkFieldsMatch checks the "Field value" list taken by required_if
+// and required_unless.
+func checkFieldsMatch(t reflect.Type, param string, cc checkContext) error {
+	pairs := strings.Fields(param)
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return ErrBadParameter
+	}
+	for i := 0; i < len(pairs); i += 2 {
+		if _, found := lookupFieldType(cc, pairs[i]); !found {
+			return ErrBadParameter
+		}
+	}
+	return nil
+}
+
+// checkFieldsPresent checks the list of fields taken by required_with
+// and required_without.
+func checkFieldsPresent(t reflect.Type, param string, cc checkContext) error {
+	names := strings.Fields(param)
+	if len(names) == 0 {
+		return ErrBadParameter
+	}
+	for _, name := range names {
+		if _, found := lookupFieldType(cc, name); !found {
+			return ErrBadParameter
+		}
+	}
+	return nil
+}
+
+// lookupFieldType returns the type of the field named by path, looked up
+// as lookupField does, with pointers dereferenced. A path going through
+// an interface cannot be followed and is assumed to exist.
+func lookupFieldType(cc checkContext, path string) (reflect.Type, bool) {
+	if t, found := fieldTypeByPath(cc.parent, path); found {
+		return t, true
+	}
+	return fieldTypeByPath(cc.root, path)
+}
+
+func fieldTypeByPath(t reflect.Type, path string) (reflect.Type, bool) {
+	if path == "" || t == nil {
+		return nil, false
+	}
+	for _, name := range strings.Split(path, ".") {
+		t = indirectType(t)
+		if t.Kind() == reflect.Interface {
+			return t, true
+		}
+		if t.Kind() != reflect.Struct {
+			return nil, false
+		}
+		f, ok := t.FieldByName(name)
+		if !ok || f.PkgPath != "" {
+			return nil, false
+		}
+		t = f.Type
+	}
+	return indirectType(t), true
+}
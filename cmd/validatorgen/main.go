@@ -0,0 +1,91 @@
+// Package validator implements value validations
+//
+// Copyright 2014 Roberto Teixeira <robteix@robteix.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command validatorgen writes ValidateFields methods checking the
+// validation tags of struct types without reflection. Validate uses
+// them in place of the tags, as described by validator.FieldsValidator.
+//
+// Usage:
+//
+//	validatorgen [-type T1,T2] [-output file] [dir]
+//
+// It writes the methods of the struct types with validation tags of the
+// package in dir, the current directory by default, to validator_gen.go.
+// It is meant to be run by go generate:
+//
+//	//go:generate go run gopkg.in/validator.v2/cmd/validatorgen
+//
+// The builtin rules other than those referring to other fields are
+// checked with plain Go code. The other rules of a field, such as those
+// set with SetValidationFunc, are checked by calling
+// validator.ValidateField. The methods must be generated again whenever
+// the tags change.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/validator.v2/internal/gen"
+)
+
+var (
+	typeNames = flag.String("type", "", "comma separated names of the types to write methods for; default all struct types with validation tags")
+	output    = flag.String("output", "", "output file name; default <dir>/validator_gen.go")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: validatorgen [-type T1,T2] [-output file] [dir]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	dir := "."
+	switch flag.NArg() {
+	case 0:
+	case 1:
+		dir = flag.Arg(0)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var names []string
+	for _, name := range strings.Split(*typeNames, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	src, skipped, err := gen.Generate(dir, names)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "validatorgen: %v\n", err)
+		os.Exit(1)
+	}
+	for _, s := range skipped {
+		fmt.Fprintf(os.Stderr, "validatorgen: skipping %s\n", s)
+	}
+	out := *output
+	if out == "" {
+		out = filepath.Join(dir, "validator_gen.go")
+	}
+	if err := os.WriteFile(out, src, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "validatorgen: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,344 @@
+// Package validator implements value validations
+//
+// Copyright 2014 Roberto Teixeira <robteix@robteix.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp/syntax"
+)
+
+// Conflicts calls the Conflicts method on the default validator.
+func Conflicts(typ interface{}) error {
+	return defaultValidator.Conflicts(typ)
+}
+
+// Conflicts reports the fields of a type whose builtin rules can never be
+// satisfied together, such as max=0,min=1 or len=10,regexp=^$. The type is
+// walked as Check does, and the conflicts are returned in an ErrorMap
+// indexed by the path of the field, as *TagErrors wrapping ErrConflict.
+// Check reports them too, along with the other mistakes it finds.
+func (mv *Validator) Conflicts(typ interface{}) error {
+	m := mv.check(typ)
+	for path, errs := range m {
+		var conflicts ErrorArray
+		for _, err := range errs {
+			if errors.Is(err, ErrConflict) {
+				conflicts = append(conflicts, err)
+			}
+		}
+		if len(conflicts) > 0 {
+			m[path] = conflicts
+		} else {
+			delete(m, path)
+		}
+	}
+	if len(m) > 0 {
+		return m
+	}
+	return nil
+}
+
+// rangeRule describes a builtin rule bounding the value of numbers,
+// or the length of strings and collections.
+type rangeRule struct {
+	lower, upper bool // whether the parameter is a lower and/or an upper bound
+}
+
+// rangeRules are the builtin rules taken into account by checkConflicts,
+// indexed by their name.
+var rangeRules = map[string]rangeRule{
+	"min": {lower: true},
+	"max": {upper: true},
+	"len": {lower: true, upper: true},
+}
+
+// bound is a lower or upper bound set by the rules of a field.
+type bound struct {
+	set   bool
+	value float64
+	src   string // what sets the bound, usually the rule as written in the tag
+}
+
+// tighter reports whether b restricts values more than o does,
+// lower telling which kind of bound they are.
+func (b bound) tighter(o bound, lower bool) bool {
+	switch {
+	case !o.set:
+		return true
+	case lower:
+		return b.value > o.value
+	}
+	return b.value < o.value
+}
+
+// excludes reports whether b rules out the value v, lower telling which
+// kind of bound b is.
+func (b bound) excludes(v float64, lower bool) bool {
+	switch {
+	case !b.set:
+		return false
+	case lower:
+		return b.value > v
+	}
+	return b.value < v
+}
+
+// checkConflicts looks for builtin rules among the tags of rs that no
+// value of type t can satisfy together. It reports the first rule found
+// to conflict with those before it, or nil. The empty value satisfies
+// the rules following omitempty, so they only conflict when those before
+// omitempty rule it out.
+func (cfg *config) checkConflicts(rs *ruleSet, t reflect.Type) *TagError {
+	var isLength bool
+	switch t.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		isLength = true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+	default:
+		return nil
+	}
+
+	var lower, upper bound
+	if t.Kind() == reflect.Array {
+		// arrays have a fixed length
+		n := float64(t.Len())
+		lower = bound{set: true, value: n, src: "type " + t.String()}
+		upper = lower
+	}
+	var nonzeroSrc string
+	for _, tg := range rs.tags {
+		if tg.Name == omitEmptyTag {
+			if nonzeroSrc == "" && !lower.excludes(0, true) && !upper.excludes(0, false) {
+				return nil
+			}
+			continue
+		}
+		if _, builtin := cfg.checkFuncs[tg.Name]; !builtin {
+			// replaced by a function of the user
+			continue
+		}
+		src := tg.Name
+		if tg.Param != "" {
+			src += "=" + tg.Param
+		}
+		var lo, hi bound
+		switch tg.Name {
+		case "nonzero":
+			nonzeroSrc = src
+			if isLength {
+				lo = bound{set: true, value: 1, src: src}
+			}
+		case "regexp":
+			if t.Kind() != reflect.String {
+				continue
+			}
+			re, err := syntax.Parse(tg.Param, syntax.Perl)
+			if err != nil {
+				continue
+			}
+			min, max, ok := matchLength(re.Simplify())
+			if !ok {
+				continue
+			}
+			lo = bound{set: true, value: float64(min), src: src}
+			if max >= 0 && anchoredStart(re) && anchoredEnd(re) {
+				hi = bound{set: true, value: float64(max), src: src}
+			}
+		default:
+			rr, found := rangeRules[tg.Name]
+			if !found {
+				continue
+			}
+			v, err := rangeParam(tg.Param, t, isLength)
+			if err != nil {
+				continue
+			}
+			b := bound{set: true, value: v, src: src}
+			if rr.lower {
+				lo = b
+			}
+			if rr.upper {
+				hi = b
+			}
+		}
+
+		if lo.set && lo.tighter(lower, true) {
+			lower = lo
+		}
+		if hi.set && hi.tighter(upper, false) {
+			upper = hi
+		}
+		var a, b string
+		switch {
+		case lower.set && upper.set && lower.value > upper.value:
+			a, b = lower.src, upper.src
+		case !isLength && nonzeroSrc != "" && lower.set && upper.set &&
+			lower.value == 0 && upper.value == 0:
+			// the only value left is zero
+			a, b = nonzeroSrc, upper.src
+		default:
+			continue
+		}
+		if a == src {
+			a, b = b, a
+		}
+		return &TagError{
+			Tag:    rs.src,
+			Rule:   tg.Name,
+			Offset: tg.Offset,
+			Err:    fmt.Errorf("%w: %s and %s", ErrConflict, a, b),
+		}
+	}
+	return nil
+}
+
+// rangeParam parses the parameter of a range rule as the builtin rule
+// does for values of type t.
+func rangeParam(param string, t reflect.Type, isLength bool) (float64, error) {
+	switch {
+	case isLength:
+		p, err := asInt(param)
+		return float64(p), err
+	case t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uintptr:
+		p, err := asUint(param)
+		return float64(p), err
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return asFloat(param)
+	}
+	p, err := asInt(param)
+	return float64(p), err
+}
+
+// matchLength returns the minimum and maximum number of runes matched by
+// re, max being -1 when there is no maximum. It reports false if re is
+// not understood.
+func matchLength(re *syntax.Regexp) (min, max int, ok bool) {
+	switch re.Op {
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine,
+		syntax.OpBeginText, syntax.OpEndText, syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return 0, 0, true
+	case syntax.OpLiteral:
+		return len(re.Rune), len(re.Rune), true
+	case syntax.OpCharClass, syntax.OpAnyCharNotNL, syntax.OpAnyChar:
+		return 1, 1, true
+	case syntax.OpCapture:
+		return matchLength(re.Sub[0])
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		smin, smax, ok := matchLength(re.Sub[0])
+		if !ok {
+			return 0, 0, false
+		}
+		rmin, rmax := 0, -1
+		switch re.Op {
+		case syntax.OpPlus:
+			rmin = 1
+		case syntax.OpQuest:
+			rmax = 1
+		case syntax.OpRepeat:
+			rmin, rmax = re.Min, re.Max
+		}
+		min = smin * rmin
+		switch {
+		case smax == 0:
+			max = 0
+		case smax < 0 || rmax < 0:
+			max = -1
+		default:
+			max = smax * rmax
+		}
+		return min, max, true
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			smin, smax, ok := matchLength(sub)
+			if !ok {
+				return 0, 0, false
+			}
+			min += smin
+			if max >= 0 {
+				if smax < 0 {
+					max = -1
+				} else {
+					max += smax
+				}
+			}
+		}
+		return min, max, true
+	case syntax.OpAlternate:
+		min = math.MaxInt32
+		for _, sub := range re.Sub {
+			smin, smax, ok := matchLength(sub)
+			if !ok {
+				return 0, 0, false
+			}
+			if smin < min {
+				min = smin
+			}
+			if max >= 0 && (smax < 0 || smax > max) {
+				max = smax
+			}
+		}
+		return min, max, true
+	}
+	return 0, 0, false
+}
+
+// anchoredStart reports whether every match of re starts at the
+// beginning of the text.
+func anchoredStart(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpBeginText:
+		return true
+	case syntax.OpCapture:
+		return anchoredStart(re.Sub[0])
+	case syntax.OpConcat:
+		return len(re.Sub) > 0 && anchoredStart(re.Sub[0])
+	case syntax.OpAlternate:
+		for _, sub := range re.Sub {
+			if !anchoredStart(sub) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// anchoredEnd reports whether every match of re ends at the end
+// of the text.
+func anchoredEnd(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpEndText:
+		return true
+	case syntax.OpCapture:
+		return anchoredEnd(re.Sub[0])
+	case syntax.OpConcat:
+		return len(re.Sub) > 0 && anchoredEnd(re.Sub[len(re.Sub)-1])
+	case syntax.OpAlternate:
+		for _, sub := range re.Sub {
+			if !anchoredEnd(sub) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
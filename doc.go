@@ -85,7 +85,40 @@ Here is the list of validator functions builtin in the package.
 	nonnil
 		Validates that the given value is not nil. Usage: nonnil
 
-Note that there are no tests to prevent conflicting validator parameters. For
+	omitempty
+		Skips the rules following it, those after dive included, when the
+		value is empty: when nonzero would fail on it, or for structs when
+		all their fields are zero. Rules placed before it still run, and
+		the fields of an empty struct are not validated either.
+		(Usage: omitempty,regexp=^https://)
+
+	eqfield, nefield
+		Validates that the value is equal (eqfield) or not equal (nefield)
+		to the value of another field. The parameter is the name of a
+		sibling field, or a dotted path to a field starting at the struct
+		given to Validate. Values must be of the same kind; numbers,
+		strings, bools and time.Time are supported. (Usage: eqfield=Password)
+
+	gtfield, gtefield, ltfield, ltefield
+		Validates that the value is greater than (gtfield), greater than or
+		equal to (gtefield), less than (ltfield) or less than or equal to
+		(ltefield) the value of another field, named as for eqfield. Numbers,
+		strings and time.Time are supported. (Usage: gtfield=StartDate)
+
+	required_if, required_unless
+		Validates that the value is nonzero, as nonzero does, when
+		(required_if) or unless (required_unless) other fields have given
+		values. The parameter is a space separated list of field names, named
+		as for eqfield, each followed by the value it must have, compared
+		with the field value as printed by fmt. All the fields must match.
+		(Usage: required_if=AccountType business)
+
+	required_with, required_without
+		Validates that the value is nonzero when any of the space separated
+		fields given as parameter is nonzero (required_with) or zero
+		(required_without). (Usage: required_with=Street City)
+
+Note that Validate does not prevent conflicting validator parameters. For
 instance, these fields will never be valid.
 
 	...
@@ -93,6 +126,170 @@ instance, these fields will never be valid.
 	B string  `validate:"len=10,regexp=^$"
 	...
 
+Conflicts finds such fields in a type, for the min, max, len, nonzero and
+regexp rules, and reports them with ErrConflict. Rules following omitempty
+do not conflict when the empty value passes those before it, as they are
+skipped for it. Check, described below, reports them as well.
+
+# Collection elements
+
+Rules placed on a slice, array or map apply to the collection as a whole, so
+min and max check its number of items. Rules placed after dive are applied to
+each of its elements instead, and errors are reported under the path of each
+element.
+
+	type T struct {
+		// at most 5 tags, each of 3 to 20 characters
+		Tags []string `validate:"max=5,dive,min=3,max=20"`
+		// a matrix of nonzero numbers
+		Matrix [][]int `validate:"dive,dive,nonzero"`
+	}
+
+An error for the third tag is found under the "Tags[2]" key. For maps, the
+values are checked by the rules following dive, and the rules found between
+keys and endkeys right after dive are applied to the keys.
+
+	type T struct {
+		// errors are keyed Labels[env](key) and Labels[env](value)
+		Labels map[string]string `validate:"dive,keys,min=2,endkeys,nonzero"`
+	}
+
+As dive, keys, endkeys, omitempty, msg and code are part of the tag syntax,
+they cannot be used as names of validation functions: SetValidationFunc and
+SetValidationFuncContext return an error for them.
+
+# Combining rules
+
+The rules of a tag must all hold. Items starting with a parenthesis or ! are
+expressions combining rules instead: | separates alternatives, of which one
+must hold, ! negates a rule and commas within parentheses group rules that
+must all hold.
+
+	type T struct {
+		// empty, or a 5 digit zip code
+		Zip string `validate:"(len=0|regexp=^[0-9]{5}$)"`
+		// anything but admin
+		Role string `validate:"nonzero,!regexp=^admin$"`
+		// 2 or 3 letters, or exactly 10 characters
+		Code string `validate:"(min=2,max=3,regexp=^[a-z]+$)|len=10"`
+	}
+
+Within expressions, parameters end at a comma, | or closing parenthesis
+unless it is escaped with a backslash or, for | and parentheses, within
+parentheses of the parameter, so regexp=^(a|b)$ needs no escaping. When no
+alternative holds, the error is an AlternativesError holding the error of
+each alternative, such as "len=0: invalid length or len=5: invalid length",
+and a negated rule that holds fails with ErrNot. Items
+that do not start with a parenthesis or ! are read as before, | included:
+min=3|len=2 is min with the parameter "3|len=2", which Check and the
+validatetag analyzer report, telling to write (min=3|len=2).
+
+# Errors
+
+Validate returns an ErrorMap indexed by the path of each invalid field,
+holding the errors returned by the rules. With SetFieldErrors, each of them
+is instead a *FieldError that records the field path, the Go and json names
+of the field, the rule that failed with its parameter, and the value that
+was checked. FieldError wraps the error returned by the rule, so the
+sentinel errors can be tested with errors.Is.
+
+	v := validator.WithFieldErrors(true)
+	if errs, ok := v.Validate(u).(validator.ErrorMap); ok {
+		for _, err := range errs["Age"] {
+			var fe *validator.FieldError
+			if errors.As(err, &fe) && errors.Is(fe, validator.ErrMin) {
+				fmt.Printf("%s failed %s=%s\n", fe.Path, fe.Rule, fe.Param)
+			}
+		}
+	}
+
+# Error messages
+
+A msg item sets the message of the rule before it, and a code item its
+Code, so both are carried by a FieldError reported for the rule, with or
+without SetFieldErrors. Given before any rule, they
+apply to all rules of the field. Commas in messages are escaped with a
+backslash.
+
+	type T struct {
+		Name string `validate:"nonzero,msg={field} is required,code=E100,max=40,msg={field} is too long"`
+		Age  int    `validate:"msg=age must be between 18 and 130,min=18,max=130"`
+	}
+
+The message replaces the text of the sentinel error in Error, which is
+still wrapped. The placeholders {field}, {label}, {path}, {rule}, {param}
+and {value} are replaced by the name, label and path of the field, the
+rule, its parameter and the value that was checked. msg and code cannot be
+used within expressions.
+
+# Translations
+
+Translate returns the errors of Validate or Valid with messages in the given
+locale. The bundled catalogs translate the errors of the builtin rules to
+English (en), Dutch (nl), German (de) and French (fr), a locale such as
+nl-BE falling back to its language. The messages of rules needing their
+parameter, such as min, are only found for FieldErrors. ValidateContext and
+ValidContext report FieldErrors, translated, when the context carries a
+locale.
+
+	err := validator.Translate(validator.WithFieldErrors(true).Validate(u), "nl")
+	// or
+	ctx = validator.ContextWithLocale(ctx, "nl")
+	err = validator.ValidateContext(ctx, u)
+
+A Translator set with SetTranslator is asked first, so it can translate the
+errors of custom rules or replace messages, and is given the locale, rule,
+error, field label, parameter and value of each error. A Catalog holds
+message templates by locale and key; DefaultCatalog returns a copy of the
+bundled ones to extend. The templates of rules counting characters or items
+are chosen by the plural form of the count.
+
+	c := validator.DefaultCatalog()
+	c["en"]["zipcode"] = "{label} is not a zip code"
+	c["en"]["min.items.one"] = "{label} needs an item"
+	validator.SetTranslator(c)
+
+# Labels
+
+The label tag gives the name of a field shown to users, set in the Label of
+its FieldErrors and used by messages and translations in place of the field
+name. ErrorMaps are still indexed by field paths.
+
+	type T struct {
+		DOB string `json:"dob" label:"Date of birth" validate:"nonzero"`
+	}
+
+SetLabelFunc replaces the label tag by a function of the struct field, and
+LabelTag returns one reading another tag.
+
+	validator.SetLabelFunc(validator.LabelTag("title"))
+
+# Checking tags
+
+Mistakes in tags are otherwise only found when a value of the type is
+validated. Check looks at the tags of a type, and of the types reachable from
+its fields, without any value and reports unknown rules, parameters that
+cannot be parsed, invalid regular expressions and rules applied to fields of a
+kind they do not support. MustCheck panics instead, for use in init functions
+or tests.
+
+	func init() {
+		validator.MustCheck(NewUserRequest{})
+	}
+
+The same mistakes can be found at compile time, without running any code, by
+the validatetag analyzer of the gopkg.in/validator.v2/validatetag package,
+which reads tags with the same grammar. The validatorvet command, in
+validatetag/cmd/validatorvet, runs it. Both live in a module of their own,
+in the validatetag directory, as they need golang.org/x/tools and a newer Go
+than the validator package. From a checkout of the repository:
+
+	cd validatetag && go install ./cmd/validatorvet
+	go vet -vettool=$(which validatorvet) ./...
+
+Its -tagnames and -rules flags set the names of the tags to check and the
+names of the rules added with SetValidationFunc.
+
 # Custom validation functions
 
 It is possible to define custom validation functions by using SetValidationFunc.
@@ -160,7 +357,10 @@ And you can delete a validation function by setting it to nil.
 	validate.SetValidationFunc("nonzero", nil)
 
 Using a non-existing validation func in a field tag will always return
-false and with error validate.ErrUnknownTag.
+false and with error validate.ErrUnknownTag. With SetFieldErrors, the error
+is a *TagError giving the struct type, the field, the full tag, the rule and
+its byte offset in the tag. Rules unable to parse their parameter report a
+*TagError wrapping ErrBadParameter the same way.
 
 Finally, package validator also provides a helper function that can be used
 to validate simple variables/values.
@@ -174,6 +374,144 @@ to validate simple variables/values.
 		// errs: [validate.ErrMin,validate.ErrMax]
 		errs = validator.Valid("hi", "nonzero,min=3,max=2")
 
+# Context
+
+ValidateContext and ValidContext are like Validate and Valid but take a
+context.Context. Functions set with SetValidationFuncContext receive it, so
+they can use deadlines or request-scoped values such as a tenant ID.
+
+	validator.SetValidationFuncContext("tenant", func(ctx context.Context, v interface{}, param string) error {
+		if v != ctx.Value(tenantKey) {
+			return errors.New("wrong tenant")
+		}
+		return nil
+	})
+
+If the context is done while a large value is walked, the validation stops
+and the context error is returned instead of an ErrorMap.
+
+# Cycles and depth
+
+Pointers, maps and slices that refer back to a value being walked, such as a
+child node pointing to its parent, are not walked again, so self-referencing
+graphs can be validated. SetReportCycles(true) reports each of them with
+ErrCycle instead of skipping them silently. SetMaxDepth limits the number of
+nested structs walked: deeper structs are reported with ErrMaxDepth.
+
+	validator.WithReportCycles(true).WithMaxDepth(10).Validate(tree)
+
+# Validate methods
+
+Types can also hold validation logic that cannot be expressed with tags by
+implementing Validatable. Once SetCallValidate is set, Validate calls the
+Validate method of every value it walks through, including nested fields and
+elements of slices and maps, and adds the error returned to those found under
+the path of the value. An ErrorMap returned by the method has its keys
+prefixed with that path.
+
+	validator.SetCallValidate(true)
+
+	type DateRange struct {
+		Start time.Time
+		End   time.Time
+	}
+
+	func (r DateRange) Validate() error {
+		if r.End.Before(r.Start) {
+			return errors.New("range ends before it starts")
+		}
+		return nil
+	}
+
+Types implementing ContextValidatable instead are also given the context and
+the Validator in use, so they can check values with the same configuration.
+
+# Generated methods
+
+The validatorgen command writes, for the struct types of a package, a
+ValidateFields method checking the tags of their fields with plain Go code
+instead of reflection, along with a ValidatorGenerated method marking it as
+generated. The methods implement FieldsValidator and Validate prefers them
+to the tags, with the same errors as a result.
+
+	//go:generate go run gopkg.in/validator.v2/cmd/validatorgen
+
+Rules other than the builtin ones, such as those set with SetValidationFunc,
+are checked by calling ValidateField from the methods. Only the default
+validator uses the methods, and only while it keeps the settings they were
+generated for; see FieldsValidator. They must be generated again whenever the
+tags change.
+
+# JSON Schema
+
+JSONSchema describes the JSON encoding of a type as a JSON Schema (draft
+2020-12) document, with the rules of its tags as keywords: min, max and len
+become minimum and maximum, minLength and maxLength, minItems and maxItems or
+minProperties and maxProperties depending on the kind of the field, regexp
+becomes pattern and nonzero and nonnil make a property required. Properties
+are named after the json tags of the fields.
+
+	schema, err := validator.JSONSchema(User{})
+	doc, err := json.Marshal(schema)
+
+Rules set with SetValidationFunc are left out unless a SchemaFunc set with
+SetSchemaFunc writes them:
+
+	validator.SetSchemaFunc("even", func(r *validator.SchemaRule) error {
+		r.Schema.Extra = map[string]interface{}{"multipleOf": 2}
+		return nil
+	})
+
+The other way around, ValidateJSON validates values decoded by encoding/json
+against a JSON Schema, for payloads described by a schema rather than a Go
+type. It supports a subset of the keywords, such as type, required, enum,
+properties, items, minimum, maximum, minLength, maxLength and pattern, and
+returns the errors in an ErrorMap indexed by the path of the values, with the
+same errors as the matching rules: ErrMin, ErrMax, ErrRegexp and so on.
+
+	var schema validator.Schema
+	err := json.Unmarshal(schemaDoc, &schema)
+	var payload interface{}
+	err = json.Unmarshal(body, &payload)
+	err = validator.ValidateJSON(payload, &schema)
+
+OpenAPI writes the same schemas in the OpenAPI 3.0 dialect, as the
+components.schemas entries of the given types and of the structs they refer
+to, with nullable properties for pointers, slices and maps:
+
+	comps, err := validator.OpenAPI(User{}, Order{})
+	doc, err := comps.YAML()
+
+# Rules without tags
+
+Types whose fields cannot be tagged, such as those of other packages or
+generated code, can have their rules registered instead. RegisterRules
+replaces the tags of the given fields and MergeRules adds to them, fields
+of nested structs being named by their path:
+
+	err := validator.RegisterRules(pb.User{}, map[string]string{
+		"Name":        "nonzero,max=40",
+		"Address.Zip": "len=5",
+	})
+
+The rules of nested structs only apply within the registered type: above,
+the addresses of users need a zip code of 5 characters, while those held
+by other types are left alone.
+
+LoadRules reads the same rules from a JSON or YAML file, grouped by type:
+
+	pb.User:
+	  Name: nonzero,max=40
+	  Address.Zip: len=5
+
+Rules builds the same rules in code, selecting fields with functions so the
+compiler checks their names:
+
+	tr := validator.Rules[pb.User]()
+	validator.Field(tr, func(u *pb.User) *string { return &u.Name }, validator.NonZero(), validator.Max(40))
+	validator.Field(tr, func(u *pb.User) *[]string { return &u.Tags }, validator.Dive(validator.Min(3)))
+	err := tr.Register()
+
 # Custom tag name
 
 In case there is a reason why one would not wish to use tag 'validate' (maybe due to
@@ -197,6 +535,28 @@ with a new call to SetTag. A way to temporarily use a different tag exists.
 	// But this will go back to using 'validate'
 	validator.Validate(t)
 
+# Field names
+
+Errors are indexed by the Go names of the fields along their path. A name
+function set with SetNameFunc names them after a tag instead, for nested
+structs and collection elements alike: JSONFieldName, YAMLFieldName,
+XMLFieldName, FormFieldName and ProtobufFieldName read the tags of the
+usual encoders, and NameTag other tags written as json tags are.
+
+	// errors are indexed "full-name" or "homes[0].town"
+	validator.WithNameFunc(validator.YAMLFieldName).Validate(t)
+
+Fields the function gives no name keep their Go name. With
+SetFlattenEmbedded, the fields of embedded structs given no name are named
+as if they belonged to the embedding struct instead, as encoding/json does.
+
+	// errors of an untagged embedded Base are indexed "id", not "Base.id"
+	validator.WithFlattenEmbedded(true).WithNameFunc(validator.JSONFieldName).Validate(t)
+
+SetPrintJSON(true) names fields after their json tag as it always did: a
+field tagged json:"-" has an empty name, and one without json tag keeps its
+Go name. It replaces the name function, and the other way round.
+
 # Multiple validators
 
 You may often need to have a different set of validation
@@ -264,5 +624,9 @@ You might use two different validators.
 It is also possible to do all of that using only the default validator as long
 as SetTag is always called before calling validator.Validate() or you chain the
 with WithTag().
+
+Validators are safe for concurrent use. A Set method called while other
+goroutines validate values does not affect the validations already running,
+which keep the configuration they started with.
 */
 package validator
@@ -0,0 +1,189 @@
+// Package validator implements value validations
+//
+// Copyright 2014 Roberto Teixeira <robteix@robteix.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validator
+
+import (
+	"errors"
+	"strings"
+
+	"gopkg.in/validator.v2/internal/tagsyntax"
+)
+
+// AlternativesError is the error of rules written as alternatives with |
+// when none of them holds. It holds the error of each alternative, a
+// *FieldError for a single rule, so errors.Is(err, ErrLen) holds when
+// one of the alternatives failed with ErrLen.
+type AlternativesError []error
+
+// Error returns the messages of the alternatives joined by "or", each
+// preceded by its rule, as in "len=2: invalid length or len=5: invalid
+// length".
+func (err AlternativesError) Error() string {
+	msgs := make([]string, len(err))
+	for i, e := range err {
+		msgs[i] = alternativeMessage(e)
+	}
+	return strings.Join(msgs, " or ")
+}
+
+// alternativeMessage returns the message of err, the error of an
+// alternative, preceded by the rule that failed. The messages of the
+// rules of a group are joined by commas, within parentheses.
+func alternativeMessage(err error) string {
+	switch err := err.(type) {
+	case *FieldError:
+		if err.Rule == "" {
+			return err.Error()
+		}
+		rule := err.Rule
+		if err.Param != "" {
+			rule += "=" + err.Param
+		}
+		return rule + ": " + err.Error()
+	case ErrorArray:
+		if len(err) == 1 {
+			return alternativeMessage(err[0])
+		}
+		msgs := make([]string, len(err))
+		for i, e := range err {
+			msgs[i] = alternativeMessage(e)
+		}
+		return "(" + strings.Join(msgs, ", ") + ")"
+	}
+	return err.Error()
+}
+
+// Unwrap returns the errors of the alternatives.
+func (err AlternativesError) Unwrap() []error {
+	return err
+}
+
+// tagExpr is a combination of rules, resolved from a tagsyntax.Expr.
+type tagExpr struct {
+	op tagsyntax.Op
+	// tag is the rule of a leaf.
+	tag tag
+	// args are the operands of And and Or, or the one of Not.
+	args []*tagExpr
+	// text is the text of the expression.
+	text string
+}
+
+// resolveExpr looks up the functions of the rules of e, which was
+// parsed from the full tag src.
+func (cfg *config) resolveExpr(e *tagsyntax.Expr, src string) (*tagExpr, error) {
+	te := &tagExpr{op: e.Op, text: e.Text}
+	if e.Op == tagsyntax.Leaf {
+		r := e.Rule
+		te.tag = tag{Name: r.Name, Param: r.Param, Offset: r.Offset, ParamOffset: r.ParamOffset}
+		var found bool
+		if te.tag.FieldFn, found = cfg.fieldFuncs[r.Name]; !found {
+			if te.tag.Fn, found = cfg.validationFuncs[r.Name]; !found {
+				return nil, unknownTag(src, r.Name, r.Offset)
+			}
+		}
+		return te, nil
+	}
+	te.args = make([]*tagExpr, len(e.Args))
+	for i, a := range e.Args {
+		var err error
+		if te.args[i], err = cfg.resolveExpr(a, src); err != nil {
+			return nil, err
+		}
+	}
+	return te, nil
+}
+
+// leaves returns the rules of e, in the order they are written.
+func (e *tagExpr) leaves() []*tag {
+	if e.op == tagsyntax.Leaf {
+		return []*tag{&e.tag}
+	}
+	var tags []*tag
+	for _, a := range e.args {
+		tags = append(tags, a.leaves()...)
+	}
+	return tags
+}
+
+// evalExpr returns nil when e holds for v, or the errors telling why
+// it does not: a *FieldError for a single rule, an ErrorArray for a
+// group, an AlternativesError for alternatives and ErrNot for a negation.
+// Rules that cannot be run make negations fail with their error.
+func (cfg *config) evalExpr(e *tagExpr, v interface{}, fc fieldContext, src string) error {
+	switch e.op {
+	case tagsyntax.Leaf:
+		if err := cfg.runTag(&e.tag, v, fc, src); err != nil {
+			return &FieldError{Rule: e.tag.Name, Param: e.tag.Param, Value: v, Err: err}
+		}
+	case tagsyntax.And:
+		var errs ErrorArray
+		for _, a := range e.args {
+			if err := cfg.evalExpr(a, v, fc, src); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if len(errs) > 0 {
+			return errs
+		}
+	case tagsyntax.Or:
+		errs := make(AlternativesError, 0, len(e.args))
+		for _, a := range e.args {
+			err := cfg.evalExpr(a, v, fc, src)
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, err)
+		}
+		return errs
+	case tagsyntax.Not:
+		err := cfg.evalExpr(e.args[0], v, fc, src)
+		switch {
+		case err == nil:
+			return ErrNot
+		case errors.Is(err, ErrBadParameter), errors.Is(err, ErrUnsupported), errors.Is(err, ErrUnknownTag):
+			return err
+		}
+	}
+	return nil
+}
+
+// fillExprErrors sets the path and field names of the errors of the
+// rules of an expression to those of fe, the error of the expression.
+func fillExprErrors(fe *FieldError, fc fieldContext) {
+	var errs []error
+	switch err := fe.Err.(type) {
+	case AlternativesError:
+		errs = err
+	case ErrorArray:
+		errs = err
+	case *FieldError:
+		errs = []error{err}
+	}
+	for _, err := range errs {
+		switch err := err.(type) {
+		case *FieldError:
+			err.Path, err.Field, err.Name, err.JSONName, err.Label = fe.Path, fe.Field, fe.Name, fe.JSONName, fe.Label
+			if te, ok := err.Err.(*TagError); ok && fc.parent.IsValid() && fe.Field != "" {
+				te.Type, te.Field = fc.parent.Type(), fe.Field
+			}
+			fillExprErrors(err, fc)
+		default:
+			fillExprErrors(&FieldError{Path: fe.Path, Field: fe.Field, Name: fe.Name, JSONName: fe.JSONName, Label: fe.Label, Err: err}, fc)
+		}
+	}
+}
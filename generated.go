@@ -0,0 +1,161 @@
+// Package validator implements value validations
+//
+// Copyright 2014 Roberto Teixeira <robteix@robteix.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validator
+
+import (
+	"context"
+	"reflect"
+)
+
+// FieldsValidator is implemented by the struct types given a ValidateFields
+// method by the validatorgen command. The method checks the tags of the
+// fields of its receiver without reflection and returns the errors found
+// in an ErrorMap, as Validate would for the fields themselves. It does not
+// walk nested structs: Validate still does, calling their own method when
+// they have one.
+//
+// Validate only uses the methods marked as generated by the ValidatorGenerated
+// method validatorgen writes along with them. Other ValidateFields methods
+// do not replace the tags.
+//
+// Validate prefers the generated method to the tags as long as the default
+// validator keeps the settings the methods were generated for: the
+// "validate" tag name, Go field names in errors, labels given by label
+// tags, the errors of the rules and the builtin rules. It stops using them
+// once SetTag, SetPrintJSON, SetNameFunc, SetFlattenEmbedded, SetLabelFunc
+// or SetFieldErrors change those settings, once a builtin rule is replaced
+// with SetValidationFunc or once a function is set with
+// SetValidationFuncContext, as generated methods have no context to give
+// it. Validators created with NewValidator never use them, and
+// neither do structs embedding a type with a ValidateFields method, which
+// may have been promoted from the embedded type.
+type FieldsValidator interface {
+	ValidateFields() error
+}
+
+// Generated is the parameter of the ValidatorGenerated method written by
+// validatorgen, which marks the ValidateFields method of its receiver as
+// generated.
+type Generated struct{}
+
+// generatedValidator is implemented by the types whose ValidateFields
+// method was written by validatorgen.
+type generatedValidator interface {
+	FieldsValidator
+	ValidatorGenerated(Generated)
+}
+
+var (
+	fieldsValidatorType    = reflect.TypeOf((*FieldsValidator)(nil)).Elem()
+	generatedValidatorType = reflect.TypeOf((*generatedValidator)(nil)).Elem()
+)
+
+// ValidateField validates the field named field of struct s, as Validate
+// would, without walking the values it holds. The errors found are
+// returned in an ErrorMap indexed by their path relative to s. It uses the
+// default validator and is meant for the methods generated by validatorgen,
+// which call it for the fields whose rules they cannot check themselves,
+// such as those set with SetValidationFunc.
+func ValidateField(s interface{}, field string) error {
+	sv := indirect(reflect.ValueOf(s))
+	if sv.Kind() != reflect.Struct {
+		return ErrUnsupported
+	}
+	cfg := defaultValidator.config()
+	p := cfg.structPlan(sv.Type(), nil)
+	for i := range p.fields {
+		fp := &p.fields[i]
+		if fp.field != field {
+			continue
+		}
+		vs := &validationState{m: make(ErrorMap), root: sv, ctx: context.Background()}
+		if errs, _ := cfg.fieldErrors(fp, sv, vs, fp.name); len(errs) > 0 {
+			vs.m[fp.name] = append(vs.m[fp.name], errs...)
+		}
+		if len(vs.m) > 0 {
+			return vs.m
+		}
+		return nil
+	}
+	return nil
+}
+
+// hasFieldsValidator reports whether the ValidateFields method of struct
+// type st can be used in place of the rules of its fields: st must have
+// the methods written by validatorgen without embedding a type
+// implementing FieldsValidator.
+func hasFieldsValidator(st reflect.Type) bool {
+	if !st.Implements(generatedValidatorType) {
+		return false
+	}
+	for i := 0; i < st.NumField(); i++ {
+		if f := st.Field(i); f.Anonymous && (f.Type.Implements(fieldsValidatorType) ||
+			reflect.PtrTo(f.Type).Implements(fieldsValidatorType)) {
+			return false
+		}
+	}
+	return true
+}
+
+// useGenerated reports whether the methods generated by validatorgen
+// give the same results as the tags under cfg.
+func (cfg *config) useGenerated() bool {
+	return cfg.generated && cfg.tagName == "validate" && !cfg.printJSON && cfg.nameFunc == nil && !cfg.flattenEmbedded && cfg.labelFunc == nil && !cfg.useFieldErrors
+}
+
+// validateGenerated validates struct sv with its ValidateFields method,
+// walking its fields as validateStruct does. The errors are added in the
+// order validateField would add them: for each field, those found while
+// walking its value come before those of its rules, except for the
+// elements the rules dive into, whose own errors come first.
+func (cfg *config) validateGenerated(p *structPlan, sv reflect.Value, vs *validationState, path string) {
+	m := vs.m
+	vs.m = make(ErrorMap)
+	fields := make(map[string]bool, len(p.fields))
+	for i := range p.fields {
+		fp := &p.fields[i]
+		fn := joinPath(path, fp.name)
+		fields[fn] = true
+		if fp.recurse && !fp.omitted(sv) {
+			cfg.deepValidateCollection(sv.Field(fp.index), vs, fn)
+		}
+	}
+	walked := vs.m
+	vs.m = m
+
+	gen := make(ErrorMap)
+	mergeErrors(gen, path, sv.Interface().(FieldsValidator).ValidateFields())
+	for k, errs := range walked {
+		if fields[k] {
+			m[k] = append(m[k], errs...)
+			m[k] = append(m[k], gen[k]...)
+			delete(gen, k)
+		}
+	}
+	for k, errs := range gen {
+		m[k] = append(m[k], errs...)
+		if !fields[k] {
+			m[k] = append(m[k], walked[k]...)
+			delete(walked, k)
+		}
+	}
+	for k, errs := range walked {
+		if !fields[k] {
+			m[k] = append(m[k], errs...)
+		}
+	}
+}
@@ -0,0 +1,569 @@
+// Package validator implements value validations
+//
+// Copyright 2014 Roberto Teixeira <robteix@robteix.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gen writes the ValidateFields methods of the validatorgen
+// command. The methods check the builtin rules of validation tags with
+// plain Go code and leave the other rules to validator.ValidateField.
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"math"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/validator.v2/internal/tagsyntax"
+)
+
+// Header starts the files written by Generate. Files starting with it
+// are ignored when reading the package.
+const Header = "// Code generated by validatorgen; DO NOT EDIT."
+
+// tagName is the tag read by the default validator, the only one
+// using generated methods.
+const tagName = "validate"
+
+// Generate returns the source of a file declaring the ValidateFields
+// methods of the struct types of the package in dir that have validation
+// tags, each marked by a ValidatorGenerated method. When names is not
+// empty, only the types it names are considered. Types already having
+// either method, generic types and types whose cross-field rules refer
+// to fields outside of the struct are skipped, the reason being given in
+// skipped.
+func Generate(dir string, names []string) (src []byte, skipped []string, err error) {
+	pkg, files, err := load(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	g := &generator{pkg: pkg, imports: map[string]bool{}}
+	for _, name := range typeNames(files, names) {
+		obj, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+		if !ok {
+			return nil, nil, fmt.Errorf("%s is not a type of package %s", name, pkg.Name())
+		}
+		if reason := g.structType(obj); reason != "" {
+			skipped = append(skipped, fmt.Sprintf("%s: %s", name, reason))
+		}
+	}
+	src, err = g.file()
+	return src, skipped, err
+}
+
+// load parses and type checks the package in dir, leaving out the
+// files written by Generate.
+func load(dir string) (*types.Package, []*ast.File, error) {
+	bp, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range bp.GoFiles {
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(f.Comments) > 0 && f.Comments[0].Pos() < f.Package &&
+			strings.HasPrefix(f.Comments[0].List[0].Text, Header) {
+			continue
+		}
+		files = append(files, f)
+	}
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		// the methods of the generated file may be missing
+		Error: func(error) {},
+	}
+	pkg, _ := conf.Check(bp.ImportPath, fset, files, nil)
+	if pkg == nil {
+		return nil, nil, fmt.Errorf("cannot type check package in %s", dir)
+	}
+	return pkg, files, nil
+}
+
+// typeNames returns the names of the struct types declared in files
+// with a validation tag, in the order of their declaration, or names
+// when given.
+func typeNames(files []*ast.File, names []string) []string {
+	if len(names) > 0 {
+		return names
+	}
+	var found []string
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if st, ok := ts.Type.(*ast.StructType); ok && hasTag(st) {
+					found = append(found, ts.Name.Name)
+				}
+			}
+		}
+	}
+	return found
+}
+
+// hasTag reports whether a field of st has a validation tag.
+func hasTag(st *ast.StructType) bool {
+	for _, f := range st.Fields.List {
+		if f.Tag == nil {
+			continue
+		}
+		lit, err := strconv.Unquote(f.Tag.Value)
+		if err != nil {
+			continue
+		}
+		if _, ok := reflect.StructTag(lit).Lookup(tagName); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// generator accumulates the generated methods.
+type generator struct {
+	pkg     *types.Package
+	methods bytes.Buffer
+	// imports are the packages the methods use, besides validator.
+	imports map[string]bool
+	// regexps are the regular expressions the methods use, compiled
+	// once in package variables.
+	regexps []string
+	// fallback is set when a method calls validator.ValidateField.
+	fallback bool
+}
+
+// structType writes the ValidateFields method of the type named by obj,
+// or returns why it cannot.
+func (g *generator) structType(obj *types.TypeName) string {
+	named, ok := obj.Type().(*types.Named)
+	if !ok {
+		return "not a defined type"
+	}
+	if named.TypeParams().Len() > 0 {
+		return "generic types are not supported"
+	}
+	st, ok := named.Underlying().(*types.Struct)
+	if !ok {
+		return "not a struct type"
+	}
+	for _, name := range []string{"ValidateFields", "ValidatorGenerated"} {
+		if obj, index, _ := types.LookupFieldOrMethod(named, true, g.pkg, name); obj != nil && len(index) == 1 {
+			// methods promoted from embedded fields are shadowed
+			return "it already has a " + name + " field or method"
+		}
+	}
+
+	var body bytes.Buffer
+	for i := 0; i < st.NumFields(); i++ {
+		v := st.Field(i)
+		tag, ok := reflect.StructTag(st.Tag(i)).Lookup(tagName)
+		if !ok || tag == "" || tag == "-" {
+			continue
+		}
+		if !v.Exported() && !v.Embedded() {
+			// ignored by the validator
+			continue
+		}
+		f := field{
+			name:  v.Name(),
+			label: reflect.StructTag(st.Tag(i)).Get("label"),
+			typ:   v.Type(),
+		}
+		if !v.Exported() {
+			fmt.Fprintf(&body, "m[%q] = append(m[%q], validator.ErrCannotValidate)\n", f.name, f.name)
+			continue
+		}
+		rs, err := tagsyntax.Parse(tag)
+		if err == nil {
+			if reason := g.checkFieldRefs(named, rs); reason != "" {
+				return fmt.Sprintf("field %s: %s", v.Name(), reason)
+			}
+		}
+		code, native := "", err == nil
+		if native {
+			code, native = g.rules(f, rs)
+		}
+		if !native {
+			g.fallback = true
+			code = fmt.Sprintf("validatorgenMerge(m, validator.ValidateField(x, %q))\n", f.name)
+		}
+		body.WriteString(code)
+	}
+
+	fmt.Fprintf(&g.methods, "\n// ValidateFields implements validator.FieldsValidator.\n")
+	fmt.Fprintf(&g.methods, "func (x %s) ValidateFields() error {\n", obj.Name())
+	fmt.Fprintf(&g.methods, "m := validator.ErrorMap{}\n%s", body.String())
+	fmt.Fprintf(&g.methods, "if len(m) > 0 {\nreturn m\n}\nreturn nil\n}\n")
+	fmt.Fprintf(&g.methods, "\n// ValidatorGenerated marks ValidateFields as written by validatorgen.\n")
+	fmt.Fprintf(&g.methods, "func (%s) ValidatorGenerated(validator.Generated) {}\n", obj.Name())
+	return ""
+}
+
+// fieldRules are the builtin rules referring to other fields, with
+// the function returning the paths of the fields named by their
+// parameter.
+var fieldRules = map[string]func(param string) []string{
+	"eqfield":  singlePath,
+	"nefield":  singlePath,
+	"gtfield":  singlePath,
+	"gtefield": singlePath,
+	"ltfield":  singlePath,
+	"ltefield": singlePath,
+
+	"required_if":      pairPaths,
+	"required_unless":  pairPaths,
+	"required_with":    strings.Fields,
+	"required_without": strings.Fields,
+}
+
+func singlePath(param string) []string {
+	return []string{param}
+}
+
+func pairPaths(param string) []string {
+	var paths []string
+	for i, s := range strings.Fields(param) {
+		if i%2 == 0 {
+			paths = append(paths, s)
+		}
+	}
+	return paths
+}
+
+// checkFieldRefs makes sure the fields named by the rules of rs can be
+// found in the struct t. The validator would look for those it cannot
+// find in the value given to Validate, which a method cannot reach.
+func (g *generator) checkFieldRefs(t types.Type, rs *tagsyntax.RuleSet) string {
+	for ; rs != nil; rs = rs.Elems {
+		sets := []*tagsyntax.RuleSet{rs}
+		if rs.Keys != nil {
+			sets = append(sets, rs.Keys)
+		}
+		for _, s := range sets {
+			for _, r := range leaves(s.Rules) {
+				paths, ok := fieldRules[r.Name]
+				if !ok {
+					continue
+				}
+				for _, path := range paths(r.Param) {
+					if !g.staticPath(t, path) {
+						return fmt.Sprintf("%s refers to %q, which may not be a field of the struct", r.Name, path)
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// leaves returns rules with expressions replaced by their rules.
+func leaves(rules []tagsyntax.Rule) []tagsyntax.Rule {
+	var rs []tagsyntax.Rule
+	for _, r := range rules {
+		if r.Expr != nil {
+			rs = append(rs, r.Expr.Leaves()...)
+		} else {
+			rs = append(rs, r)
+		}
+	}
+	return rs
+}
+
+// staticPath reports whether path names a field of struct t whatever
+// the value of the struct, going through structs and pointers only.
+func (g *generator) staticPath(t types.Type, path string) bool {
+	if path == "" {
+		return false
+	}
+	for _, name := range strings.Split(path, ".") {
+		t = deref(t)
+		if _, ok := t.Underlying().(*types.Struct); !ok {
+			return false
+		}
+		obj, _, _ := types.LookupFieldOrMethod(t, true, g.pkg, name)
+		v, ok := obj.(*types.Var)
+		if !ok || !v.Exported() {
+			return false
+		}
+		t = v.Type()
+	}
+	return true
+}
+
+func deref(t types.Type) types.Type {
+	for {
+		p, ok := t.Underlying().(*types.Pointer)
+		if !ok {
+			return t
+		}
+		t = p.Elem()
+	}
+}
+
+// field describes the struct field whose rules are written.
+type field struct {
+	name string
+	// label is given by the label tag of the field.
+	label string
+	typ   types.Type
+}
+
+// rules returns the code checking the rules of rs against field f. It
+// reports false when a rule cannot be checked without reflection, in
+// which case the field is left to validator.ValidateField.
+func (g *generator) rules(f field, rs *tagsyntax.RuleSet) (string, bool) {
+	if rs.Keys != nil || rs.Elems != nil || rs.Message != "" || rs.Code != "" || f.label != "" {
+		return "", false
+	}
+	for _, r := range rs.Rules {
+		if r.Message != "" || r.Code != "" {
+			return "", false
+		}
+	}
+	// rules apply to the value pointers refer to, and only nonzero
+	// and nonnil fail on nil pointers
+	expr, ptr := "x."+f.name, false
+	t := f.typ
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		ptr, t = true, p.Elem()
+		if _, ok := t.Underlying().(*types.Pointer); ok {
+			return "", false
+		}
+	}
+	val := expr
+	if ptr {
+		val = "*" + expr
+	}
+	k, ok := kindOf(t)
+	if !ok {
+		return "", false
+	}
+	str := val
+	if _, basic := t.(*types.Basic); k == reflect.String && !basic {
+		str = "string(" + val + ")"
+	}
+
+	var b bytes.Buffer
+	for _, r := range rs.Rules {
+		fail := func(cond, sentinel string) {
+			fmt.Fprintf(&b, "if %s {\nm[%q] = append(m[%q], validator.%s)\n}\n", cond, f.name, f.name, sentinel)
+		}
+		switch r.Name {
+		case "nonzero":
+			if k == reflect.Func {
+				// unsupported, reported by the validator
+				return "", false
+			}
+			cond := g.zero(k, val)
+			switch {
+			case !ptr:
+				if cond != "" {
+					fail(cond, "ErrZeroValue")
+				}
+			case cond == "":
+				fail(expr+" == nil", "ErrZeroValue")
+			default:
+				fail(expr+" == nil || "+cond, "ErrZeroValue")
+			}
+		case "nonnil":
+			switch {
+			case k == reflect.Func && ptr:
+				// the function may be nil too
+				return "", false
+			case k == reflect.Func || ptr:
+				fail(expr+" == nil", "ErrZeroValue")
+			}
+		case "len", "min", "max":
+			cond, ok := g.compare(k, val, str, r.Name, r.Param)
+			if !ok {
+				return "", false
+			}
+			if ptr {
+				cond = expr + " != nil && " + cond
+			}
+			fail(cond, map[string]string{"len": "ErrLen", "min": "ErrMin", "max": "ErrMax"}[r.Name])
+		case "regexp":
+			if k != reflect.String {
+				return "", false
+			}
+			if _, err := regexp.Compile(r.Param); err != nil {
+				return "", false
+			}
+			cond := fmt.Sprintf("!%s.MatchString(%s)", g.regexpVar(r.Param), str)
+			if ptr {
+				cond = expr + " != nil && " + cond
+			}
+			fail(cond, "ErrRegexp")
+		default:
+			return "", false
+		}
+	}
+	return b.String(), true
+}
+
+// zero returns the condition under which val, of kind k, is found
+// zero by nonzero, or "" if it never is.
+func (g *generator) zero(k reflect.Kind, val string) string {
+	switch k {
+	case reflect.String:
+		return val + ` == ""`
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return "len(" + val + ") == 0"
+	case reflect.Int64, reflect.Uint64, reflect.Float64:
+		return val + " == 0"
+	case reflect.Bool:
+		return "!" + val
+	}
+	return ""
+}
+
+// compare returns the condition under which val, of kind k, fails the
+// range rule name with parameter param. str is val converted to string
+// when k is String. It reports false if the rule does not support k or
+// cannot parse param.
+func (g *generator) compare(k reflect.Kind, val, str, name, param string) (string, bool) {
+	op := map[string]string{"len": "!=", "min": "<", "max": ">"}[name]
+	switch k {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array, reflect.Int64:
+		p, err := strconv.ParseInt(param, 0, 64)
+		if err != nil {
+			return "", false
+		}
+		switch k {
+		case reflect.String:
+			g.imports["unicode/utf8"] = true
+			val = "int64(utf8.RuneCountInString(" + str + "))"
+		case reflect.Int64:
+			val = "int64(" + val + ")"
+		default:
+			val = "int64(len(" + val + "))"
+		}
+		return fmt.Sprintf("%s %s %d", val, op, p), true
+	case reflect.Uint64:
+		p, err := strconv.ParseUint(param, 0, 64)
+		if err != nil {
+			return "", false
+		}
+		return fmt.Sprintf("uint64(%s) %s %d", val, op, p), true
+	case reflect.Float64:
+		p, err := strconv.ParseFloat(param, 64)
+		if err != nil || math.IsNaN(p) || math.IsInf(p, 0) {
+			return "", false
+		}
+		return fmt.Sprintf("float64(%s) %s %s", val, op, strconv.FormatFloat(p, 'g', -1, 64)), true
+	}
+	return "", false
+}
+
+// regexpVar returns the name of the variable holding the compiled
+// regular expression expr.
+func (g *generator) regexpVar(expr string) string {
+	i := sort.SearchStrings(g.regexps, expr)
+	if i == len(g.regexps) || g.regexps[i] != expr {
+		g.regexps = append(g.regexps, "")
+		copy(g.regexps[i+1:], g.regexps[i:])
+		g.regexps[i] = expr
+	}
+	return "validatorgenRegexps[" + strconv.Quote(expr) + "]"
+}
+
+// kindOf returns the reflect.Kind of the values of type t, numbers being
+// reported as Int64, Uint64 or Float64 whatever their size. It reports
+// false for the kinds generated methods leave to the validator.
+func kindOf(t types.Type) (reflect.Kind, bool) {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsString != 0:
+			return reflect.String, true
+		case u.Info()&types.IsBoolean != 0:
+			return reflect.Bool, true
+		case u.Info()&types.IsFloat != 0:
+			return reflect.Float64, true
+		case u.Info()&types.IsUnsigned != 0:
+			return reflect.Uint64, true
+		case u.Info()&types.IsInteger != 0:
+			return reflect.Int64, true
+		}
+	case *types.Struct:
+		return reflect.Struct, true
+	case *types.Slice:
+		return reflect.Slice, true
+	case *types.Array:
+		return reflect.Array, true
+	case *types.Map:
+		return reflect.Map, true
+	case *types.Signature:
+		return reflect.Func, true
+	}
+	return reflect.Invalid, false
+}
+
+// file returns the formatted source of the generated file.
+func (g *generator) file() ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s\n\npackage %s\n\nimport (\n", Header, g.pkg.Name())
+	if len(g.regexps) > 0 {
+		g.imports["regexp"] = true
+	}
+	var imports []string
+	for path := range g.imports {
+		imports = append(imports, path)
+	}
+	sort.Strings(imports)
+	for _, path := range imports {
+		fmt.Fprintf(&b, "%q\n", path)
+	}
+	fmt.Fprintf(&b, "\n%q\n)\n", "gopkg.in/validator.v2")
+
+	if len(g.regexps) > 0 {
+		b.WriteString("\n// validatorgenRegexps holds the regular expressions of the regexp rules.\n")
+		b.WriteString("var validatorgenRegexps = map[string]*regexp.Regexp{\n")
+		for _, expr := range g.regexps {
+			fmt.Fprintf(&b, "%q: regexp.MustCompile(%q),\n", expr, expr)
+		}
+		b.WriteString("}\n")
+	}
+	b.Write(g.methods.Bytes())
+	if g.fallback {
+		b.WriteString(`
+// validatorgenMerge adds the errors returned by validator.ValidateField to m.
+func validatorgenMerge(m validator.ErrorMap, err error) {
+	fm, _ := err.(validator.ErrorMap)
+	for k, errs := range fm {
+		m[k] = append(m[k], errs...)
+	}
+}
+`)
+	}
+	return format.Source(b.Bytes())
+}
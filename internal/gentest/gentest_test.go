@@ -0,0 +1,137 @@
+// Package validator implements value validations
+//
+// Copyright 2014 Roberto Teixeira <robteix@robteix.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gentest
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"gopkg.in/validator.v2"
+	"gopkg.in/validator.v2/internal/gen"
+)
+
+var errNotEven = errors.New("not even")
+
+func even(v interface{}, param string) error {
+	if n, ok := v.(int); ok && n%2 != 0 {
+		return errNotEven
+	}
+	return nil
+}
+
+// reflective validates with the tags only, as it is not the default
+// validator.
+var reflective = validator.NewValidator()
+
+func init() {
+	validator.SetValidationFunc("even", even)
+	reflective.SetValidationFunc("even", even)
+}
+
+func TestUpToDate(t *testing.T) {
+	src, _, err := gen.Generate(".", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("validator_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, got) {
+		t.Errorf("validator_gen.go is out of date, run go generate")
+	}
+}
+
+func TestSameErrors(t *testing.T) {
+	name, short, code, age, zero := "Robert", "R", Code("abc"), 12, 0
+	pzero := &zero
+	handler := func() {}
+	var nilHandler func()
+	values := []interface{}{
+		Scalars{},
+		Scalars{Name: "Robert", Unicode: "日本語", Code: "AB12", Age: 30, Level: 3, Count: 16, Ratio: 1, Enabled: true, Exact: 2.25},
+		Scalars{Name: "a very long name", Unicode: "abcd", Code: "ab", Age: 200, Level: 6, Count: 17, Ratio: 0.25, Exact: 2},
+		&Scalars{Name: "x", Level: -1},
+		Collections{},
+		Collections{
+			Tags:   []string{"a", "b", "c", "d"},
+			Attrs:  map[string]string{"a": "b"},
+			Digest: [4]byte{1, 2, 3, 4},
+			Codes:  []Code{"ABC", "abc"},
+			Flags:  map[string]int{"a": 2, "bb": 1},
+		},
+		Pointers{},
+		Pointers{
+			Name:     &short,
+			Age:      &age,
+			Code:     &code,
+			Required: &Scalars{},
+			Present:  &time.Time{},
+			Callback: func() error { return nil },
+			Handler:  &nilHandler,
+			Deep:     &pzero,
+			Any:      "",
+			Matrix:   &map[string]string{"a": "b", "c": "d"},
+		},
+		Pointers{Name: &name, Handler: &handler, Any: 1},
+		Nested{},
+		Nested{
+			Base:     Scalars{Name: "Robert"},
+			Inner:    Pointers{Name: &short},
+			Optional: &Scalars{Age: 20},
+			List:     []*Scalars{{}, nil, {Level: 9}},
+			Checked:  Checked{Value: 11},
+			Items:    []Checked{{Value: 0}, {Value: 3}, {Value: 12}},
+			Spare:    Scalars{Age: 20},
+		},
+		Extended{Note: "too long"},
+		Extended{Scalars: Scalars{Name: "Robert"}},
+		Others{},
+		Others{Password: "secret", Confirm: "other", Kind: "other", Even: 3},
+		Others{Password: "long secret", Confirm: "long secret", Kind: "simple", Even: 4, embedded: embedded{Value: 1}},
+		Outside{Confirm: "x"},
+		[]Checked{{Value: 1}, {Value: 20}},
+		map[string]*Nested{"a": {Checked: Checked{Value: 5}}},
+	}
+	for _, v := range values {
+		got, want := validator.Validate(v), reflective.Validate(v)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Validate(%#v):\ngenerated:  %#v\nreflective: %#v", v, got, want)
+		}
+		if got == nil {
+			t.Logf("Validate(%#v) found no errors", v)
+		}
+	}
+}
+
+func TestMethods(t *testing.T) {
+	// the methods validate the fields only, not the values they hold
+	err := Nested{Base: Scalars{}, Checked: Checked{Value: 11}}.ValidateFields()
+	want := validator.ErrorMap{
+		"Optional": {validator.ErrZeroValue},
+	}
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("ValidateFields() = %#v, want %#v", err, want)
+	}
+	if err := (Others{Even: 3}).ValidateFields(); err.(validator.ErrorMap)["Even"][0] != errNotEven {
+		t.Errorf("ValidateFields() = %v, want errors from the custom rule", err)
+	}
+}
@@ -0,0 +1,130 @@
+// Package validator implements value validations
+//
+// Copyright 2014 Roberto Teixeira <robteix@robteix.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gentest holds types with methods written by validatorgen, to
+// check they find the same errors as the validator does with the tags.
+package gentest
+
+import (
+	"errors"
+	"time"
+)
+
+//go:generate go run gopkg.in/validator.v2/cmd/validatorgen
+
+// Code is a defined string type.
+type Code string
+
+// Level is a defined integer type.
+type Level int8
+
+// Scalars uses the builtin rules on scalar types.
+type Scalars struct {
+	Name     string  `validate:"nonzero,min=2,max=10" json:"name,omitempty"`
+	Unicode  string  `validate:"len=3"`
+	Code     Code    `validate:"regexp=^[A-Z]{2}[0-9]+$"`
+	Age      int     `validate:"min=18,max=130"`
+	Level    Level   `validate:"nonzero,max=5"`
+	Count    uint16  `validate:"min=1,max=0x10"`
+	Ratio    float32 `validate:"min=0.5,max=1.5"`
+	Enabled  bool    `validate:"nonzero"`
+	Exact    float64 `validate:"len=2.25"`
+	Untagged string
+	Skipped  string `validate:"-"`
+}
+
+// Collections uses the builtin rules on slices, maps and arrays.
+type Collections struct {
+	Tags   []string          `validate:"nonzero,max=3"`
+	Attrs  map[string]string `validate:"min=1"`
+	Digest [4]byte           `validate:"len=4"`
+	Empty  [0]int            `validate:"nonzero"`
+	Codes  []Code            `validate:"min=1,dive,regexp=^[A-Z]+$"`
+	Flags  map[string]int    `validate:"dive,keys,min=2,endkeys,max=1"`
+}
+
+// Pointers uses the builtin rules on pointers.
+type Pointers struct {
+	Name     *string            `validate:"nonzero,min=2"`
+	Age      *int               `validate:"min=18"`
+	Code     *Code              `validate:"regexp=^[a-z]+$"`
+	Required *Scalars           `validate:"nonnil"`
+	Present  *time.Time         `validate:"nonzero"`
+	Callback func() error       `validate:"nonnil"`
+	Handler  *func()            `validate:"nonnil"`
+	Deep     **int              `validate:"min=1"`
+	Any      interface{}        `validate:"nonzero"`
+	Matrix   *map[string]string `validate:"max=1"`
+}
+
+// Nested holds structs, which the validator walks.
+type Nested struct {
+	Base     Scalars
+	Inner    Pointers   `validate:"nonzero"`
+	Optional *Scalars   `validate:"nonzero"`
+	List     []*Scalars `validate:"max=1"`
+	Checked  Checked
+	Items    []Checked `validate:"dive,nonzero"`
+	Spare    Scalars   `validate:"omitempty"`
+}
+
+// Extended embeds a type with a ValidateFields method, so the validator
+// uses its tags rather than its own method.
+type Extended struct {
+	Scalars
+	Note string `validate:"max=5"`
+}
+
+// Checked has a Validate method, called by the validator along with
+// the ValidateFields method.
+type Checked struct {
+	Value int `validate:"max=10"`
+}
+
+// ErrOdd is returned by the Validate method of Checked.
+var ErrOdd = errors.New("odd value")
+
+// Validate implements validator.Validatable.
+func (c Checked) Validate() error {
+	if c.Value%2 != 0 {
+		return ErrOdd
+	}
+	return nil
+}
+
+// Others uses rules the methods leave to the validator.
+type Others struct {
+	Password string    `validate:"min=8"`
+	Confirm  string    `validate:"eqfield=Password"`
+	Kind     string    `validate:"nonzero"`
+	Detail   string    `validate:"required_if=Kind other"`
+	Even     int       `validate:"even,min=2"`
+	Unknown  string    `validate:"nosuchrule"`
+	Bad      int       `validate:"min=abc"`
+	Regexp   string    `validate:"regexp=(["`
+	Complex  complex64 `validate:"nonzero"`
+	embedded `validate:"nonzero"`
+}
+
+type embedded struct {
+	Value int `validate:"min=1"`
+}
+
+// Outside refers to a field that may be found in the value given to
+// Validate, which generated methods cannot reach: it gets none.
+type Outside struct {
+	Confirm string `validate:"eqfield=Password"`
+}
@@ -0,0 +1,240 @@
+// Code generated by validatorgen; DO NOT EDIT.
+
+package gentest
+
+import (
+	"regexp"
+	"unicode/utf8"
+
+	"gopkg.in/validator.v2"
+)
+
+// validatorgenRegexps holds the regular expressions of the regexp rules.
+var validatorgenRegexps = map[string]*regexp.Regexp{
+	"^[A-Z]{2}[0-9]+$": regexp.MustCompile("^[A-Z]{2}[0-9]+$"),
+	"^[a-z]+$":         regexp.MustCompile("^[a-z]+$"),
+}
+
+// ValidateFields implements validator.FieldsValidator.
+func (x Scalars) ValidateFields() error {
+	m := validator.ErrorMap{}
+	if x.Name == "" {
+		m["Name"] = append(m["Name"], validator.ErrZeroValue)
+	}
+	if int64(utf8.RuneCountInString(x.Name)) < 2 {
+		m["Name"] = append(m["Name"], validator.ErrMin)
+	}
+	if int64(utf8.RuneCountInString(x.Name)) > 10 {
+		m["Name"] = append(m["Name"], validator.ErrMax)
+	}
+	if int64(utf8.RuneCountInString(x.Unicode)) != 3 {
+		m["Unicode"] = append(m["Unicode"], validator.ErrLen)
+	}
+	if !validatorgenRegexps["^[A-Z]{2}[0-9]+$"].MatchString(string(x.Code)) {
+		m["Code"] = append(m["Code"], validator.ErrRegexp)
+	}
+	if int64(x.Age) < 18 {
+		m["Age"] = append(m["Age"], validator.ErrMin)
+	}
+	if int64(x.Age) > 130 {
+		m["Age"] = append(m["Age"], validator.ErrMax)
+	}
+	if x.Level == 0 {
+		m["Level"] = append(m["Level"], validator.ErrZeroValue)
+	}
+	if int64(x.Level) > 5 {
+		m["Level"] = append(m["Level"], validator.ErrMax)
+	}
+	if uint64(x.Count) < 1 {
+		m["Count"] = append(m["Count"], validator.ErrMin)
+	}
+	if uint64(x.Count) > 16 {
+		m["Count"] = append(m["Count"], validator.ErrMax)
+	}
+	if float64(x.Ratio) < 0.5 {
+		m["Ratio"] = append(m["Ratio"], validator.ErrMin)
+	}
+	if float64(x.Ratio) > 1.5 {
+		m["Ratio"] = append(m["Ratio"], validator.ErrMax)
+	}
+	if !x.Enabled {
+		m["Enabled"] = append(m["Enabled"], validator.ErrZeroValue)
+	}
+	if float64(x.Exact) != 2.25 {
+		m["Exact"] = append(m["Exact"], validator.ErrLen)
+	}
+	if len(m) > 0 {
+		return m
+	}
+	return nil
+}
+
+// ValidatorGenerated marks ValidateFields as written by validatorgen.
+func (Scalars) ValidatorGenerated(validator.Generated) {}
+
+// ValidateFields implements validator.FieldsValidator.
+func (x Collections) ValidateFields() error {
+	m := validator.ErrorMap{}
+	if len(x.Tags) == 0 {
+		m["Tags"] = append(m["Tags"], validator.ErrZeroValue)
+	}
+	if int64(len(x.Tags)) > 3 {
+		m["Tags"] = append(m["Tags"], validator.ErrMax)
+	}
+	if int64(len(x.Attrs)) < 1 {
+		m["Attrs"] = append(m["Attrs"], validator.ErrMin)
+	}
+	if int64(len(x.Digest)) != 4 {
+		m["Digest"] = append(m["Digest"], validator.ErrLen)
+	}
+	if len(x.Empty) == 0 {
+		m["Empty"] = append(m["Empty"], validator.ErrZeroValue)
+	}
+	validatorgenMerge(m, validator.ValidateField(x, "Codes"))
+	validatorgenMerge(m, validator.ValidateField(x, "Flags"))
+	if len(m) > 0 {
+		return m
+	}
+	return nil
+}
+
+// ValidatorGenerated marks ValidateFields as written by validatorgen.
+func (Collections) ValidatorGenerated(validator.Generated) {}
+
+// ValidateFields implements validator.FieldsValidator.
+func (x Pointers) ValidateFields() error {
+	m := validator.ErrorMap{}
+	if x.Name == nil || *x.Name == "" {
+		m["Name"] = append(m["Name"], validator.ErrZeroValue)
+	}
+	if x.Name != nil && int64(utf8.RuneCountInString(*x.Name)) < 2 {
+		m["Name"] = append(m["Name"], validator.ErrMin)
+	}
+	if x.Age != nil && int64(*x.Age) < 18 {
+		m["Age"] = append(m["Age"], validator.ErrMin)
+	}
+	if x.Code != nil && !validatorgenRegexps["^[a-z]+$"].MatchString(string(*x.Code)) {
+		m["Code"] = append(m["Code"], validator.ErrRegexp)
+	}
+	if x.Required == nil {
+		m["Required"] = append(m["Required"], validator.ErrZeroValue)
+	}
+	if x.Present == nil {
+		m["Present"] = append(m["Present"], validator.ErrZeroValue)
+	}
+	if x.Callback == nil {
+		m["Callback"] = append(m["Callback"], validator.ErrZeroValue)
+	}
+	validatorgenMerge(m, validator.ValidateField(x, "Handler"))
+	validatorgenMerge(m, validator.ValidateField(x, "Deep"))
+	validatorgenMerge(m, validator.ValidateField(x, "Any"))
+	if x.Matrix != nil && int64(len(*x.Matrix)) > 1 {
+		m["Matrix"] = append(m["Matrix"], validator.ErrMax)
+	}
+	if len(m) > 0 {
+		return m
+	}
+	return nil
+}
+
+// ValidatorGenerated marks ValidateFields as written by validatorgen.
+func (Pointers) ValidatorGenerated(validator.Generated) {}
+
+// ValidateFields implements validator.FieldsValidator.
+func (x Nested) ValidateFields() error {
+	m := validator.ErrorMap{}
+	if x.Optional == nil {
+		m["Optional"] = append(m["Optional"], validator.ErrZeroValue)
+	}
+	if int64(len(x.List)) > 1 {
+		m["List"] = append(m["List"], validator.ErrMax)
+	}
+	validatorgenMerge(m, validator.ValidateField(x, "Items"))
+	validatorgenMerge(m, validator.ValidateField(x, "Spare"))
+	if len(m) > 0 {
+		return m
+	}
+	return nil
+}
+
+// ValidatorGenerated marks ValidateFields as written by validatorgen.
+func (Nested) ValidatorGenerated(validator.Generated) {}
+
+// ValidateFields implements validator.FieldsValidator.
+func (x Extended) ValidateFields() error {
+	m := validator.ErrorMap{}
+	if int64(utf8.RuneCountInString(x.Note)) > 5 {
+		m["Note"] = append(m["Note"], validator.ErrMax)
+	}
+	if len(m) > 0 {
+		return m
+	}
+	return nil
+}
+
+// ValidatorGenerated marks ValidateFields as written by validatorgen.
+func (Extended) ValidatorGenerated(validator.Generated) {}
+
+// ValidateFields implements validator.FieldsValidator.
+func (x Checked) ValidateFields() error {
+	m := validator.ErrorMap{}
+	if int64(x.Value) > 10 {
+		m["Value"] = append(m["Value"], validator.ErrMax)
+	}
+	if len(m) > 0 {
+		return m
+	}
+	return nil
+}
+
+// ValidatorGenerated marks ValidateFields as written by validatorgen.
+func (Checked) ValidatorGenerated(validator.Generated) {}
+
+// ValidateFields implements validator.FieldsValidator.
+func (x Others) ValidateFields() error {
+	m := validator.ErrorMap{}
+	if int64(utf8.RuneCountInString(x.Password)) < 8 {
+		m["Password"] = append(m["Password"], validator.ErrMin)
+	}
+	validatorgenMerge(m, validator.ValidateField(x, "Confirm"))
+	if x.Kind == "" {
+		m["Kind"] = append(m["Kind"], validator.ErrZeroValue)
+	}
+	validatorgenMerge(m, validator.ValidateField(x, "Detail"))
+	validatorgenMerge(m, validator.ValidateField(x, "Even"))
+	validatorgenMerge(m, validator.ValidateField(x, "Unknown"))
+	validatorgenMerge(m, validator.ValidateField(x, "Bad"))
+	validatorgenMerge(m, validator.ValidateField(x, "Regexp"))
+	validatorgenMerge(m, validator.ValidateField(x, "Complex"))
+	m["embedded"] = append(m["embedded"], validator.ErrCannotValidate)
+	if len(m) > 0 {
+		return m
+	}
+	return nil
+}
+
+// ValidatorGenerated marks ValidateFields as written by validatorgen.
+func (Others) ValidatorGenerated(validator.Generated) {}
+
+// ValidateFields implements validator.FieldsValidator.
+func (x embedded) ValidateFields() error {
+	m := validator.ErrorMap{}
+	if int64(x.Value) < 1 {
+		m["Value"] = append(m["Value"], validator.ErrMin)
+	}
+	if len(m) > 0 {
+		return m
+	}
+	return nil
+}
+
+// ValidatorGenerated marks ValidateFields as written by validatorgen.
+func (embedded) ValidatorGenerated(validator.Generated) {}
+
+// validatorgenMerge adds the errors returned by validator.ValidateField to m.
+func validatorgenMerge(m validator.ErrorMap, err error) {
+	fm, _ := err.(validator.ErrorMap)
+	for k, errs := range fm {
+		m[k] = append(m[k], errs...)
+	}
+}
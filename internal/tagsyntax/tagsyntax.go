@@ -0,0 +1,467 @@
+// Package validator implements value validations
+//
+// Copyright 2014 Roberto Teixeira <robteix@robteix.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tagsyntax implements the grammar of validation tags. It is
+// shared by the validator package and its vet analyzer so that both
+// read tags the same way.
+package tagsyntax
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Keywords of the tag grammar that separate the rules of a
+// collection from those of its elements.
+const (
+	// Dive starts the rules applied to each element of a
+	// slice or array, or to each value of a map.
+	Dive = "dive"
+	// Keys, right after Dive, starts the rules applied to
+	// each key of a map, up to EndKeys.
+	Keys    = "keys"
+	EndKeys = "endkeys"
+	// OmitEmpty skips the rules following it, those of the
+	// elements included, for zero values. It is a rule of its
+	// own in RuleSet.Rules, taking no parameter.
+	OmitEmpty = "omitempty"
+	// Msg and Code set the message and the code of the errors of
+	// the rule before them, or of all the rules that follow when
+	// they come first. They are not rules: Parse stores them in
+	// Rule and RuleSet.
+	Msg  = "msg"
+	Code = "code"
+)
+
+// IsKeyword reports whether name is a keyword of the tag grammar,
+// which cannot be the name of a rule.
+func IsKeyword(name string) bool {
+	switch name {
+	case Dive, Keys, EndKeys, OmitEmpty, Msg, Code:
+		return true
+	}
+	return false
+}
+
+// Rule is a rule of a tag, such as "min=3".
+type Rule struct {
+	Name        string // name of the rule
+	Param       string // parameter of the rule, with escaped commas unescaped
+	Offset      int    // byte offset of the name within the tag
+	ParamOffset int    // byte offset of the parameter within the tag
+	// Expr is set, with an empty Name, for an item combining
+	// rules with |, ! and parentheses, such as "(len=0|len=5)".
+	Expr *Expr
+	// Message and Code are given by the Msg and Code items
+	// following the rule.
+	Message string
+	Code    string
+}
+
+// Op is the operator of an Expr.
+type Op int
+
+const (
+	// Leaf is a single rule.
+	Leaf Op = iota
+	// And holds when all its operands hold. Its operands are
+	// separated by commas within parentheses.
+	And
+	// Or holds when any of its operands holds. Its operands are
+	// separated by |.
+	Or
+	// Not holds when its operand does not. It is written with !.
+	Not
+)
+
+// Expr is a combination of rules. Items of a tag starting with ( or !
+// are expressions: within them, parameters end at an unescaped comma, |
+// or closing parenthesis that is not balanced within the parameter, and
+// \|, \( and \) stand for |, ( and ).
+type Expr struct {
+	Op     Op
+	Rule   Rule    // the rule of a Leaf
+	Args   []*Expr // the operands of And and Or, or the one of Not
+	Text   string  // text of the expression, spaces trimmed
+	Offset int     // byte offset of the expression within the tag
+}
+
+// Leaves returns the rules of e, in the order they are written.
+func (e *Expr) Leaves() []Rule {
+	if e.Op == Leaf {
+		return []Rule{e.Rule}
+	}
+	var rules []Rule
+	for _, a := range e.Args {
+		rules = append(rules, a.Leaves()...)
+	}
+	return rules
+}
+
+// RuleSet holds the rules of a tag.
+type RuleSet struct {
+	// Rules are the rules applied to the value itself.
+	Rules []Rule
+	// Keys are the rules applied to the keys of a map.
+	Keys *RuleSet
+	// Elems are the rules applied to the elements of a
+	// slice or array, or to the values of a map.
+	Elems *RuleSet
+	// Dive is the byte offset within the tag of the Dive
+	// keyword starting Keys and Elems.
+	Dive int
+	// Message and Code are given by the Msg and Code items
+	// before the first rule, for the rules without their own.
+	Message string
+	Code    string
+}
+
+// Error is the error returned by Parse for a tag that does not follow
+// the grammar: a rule without a name, a keyword or character out of
+// place, or a parenthesis left open.
+type Error struct {
+	Rule   string // the keyword or character out of place, or "" for a missing name
+	Offset int    // byte offset of the problem within the tag
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	switch e.Rule {
+	case "":
+		return fmt.Sprintf("missing rule name at offset %d", e.Offset)
+	case "(":
+		return fmt.Sprintf("unclosed ( at offset %d", e.Offset)
+	}
+	return fmt.Sprintf("unexpected %s at offset %d", e.Rule, e.Offset)
+}
+
+// Item is one of the comma separated items of a tag.
+type Item struct {
+	Text   string // text of the item, escaped commas included
+	Offset int    // byte offset of the item within the tag
+}
+
+// Split splits tag by its unescaped commas, leaving those within the
+// parentheses of expressions.
+func Split(tag string) []Item {
+	items := []Item{}
+	last, depth, expr := 0, 0, isExpr(tag)
+	for i := 0; i < len(tag); i++ {
+		switch c := tag[i]; {
+		case c == '\\':
+			// the next byte is escaped
+			i++
+		case c == '(' && expr:
+			depth++
+		case c == ')' && expr && depth > 0:
+			depth--
+		case c == ',' && depth == 0:
+			items = append(items, Item{Text: tag[last:i], Offset: last})
+			last = i + 1
+			expr = isExpr(tag[last:])
+		}
+	}
+	items = append(items, Item{Text: tag[last:], Offset: last})
+	return items
+}
+
+// isExpr reports whether the item at the start of s is an expression.
+func isExpr(s string) bool {
+	s = strings.TrimLeft(s, " ")
+	return s != "" && (s[0] == '(' || s[0] == '!')
+}
+
+// Parse parses tag into its rules. It only checks the grammar: the
+// names and parameters of the rules are left for the caller to check.
+func Parse(tag string) (*RuleSet, error) {
+	rs, _, err := parseRuleSet(Split(tag), false, len(tag))
+	if err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// parseRuleSet parses the items of tl up to the end of the list, or
+// up to EndKeys when parsing the rules of map keys, and returns the
+// items left. end is the length of the tag.
+func parseRuleSet(tl []Item, inKeys bool, end int) (*RuleSet, []Item, error) {
+	rs := &RuleSet{Rules: make([]Rule, 0, len(tl))}
+	for len(tl) > 0 {
+		item := tl[0]
+		tl = tl[1:]
+		switch name := strings.Trim(item.Text, " "); name {
+		case Dive:
+			rs.Dive = item.Offset + leadingSpaces(item.Text)
+			if len(tl) > 0 && strings.Trim(tl[0].Text, " ") == Keys {
+				keys, rest, err := parseRuleSet(tl[1:], true, end)
+				if err != nil {
+					return nil, nil, err
+				}
+				rs.Keys, tl = keys, rest
+			}
+			elems, rest, err := parseRuleSet(tl, inKeys, end)
+			if err != nil {
+				return nil, nil, err
+			}
+			rs.Elems = elems
+			return rs, rest, nil
+		case Keys:
+			// only valid right after dive
+			return nil, nil, &Error{Rule: name, Offset: item.Offset + leadingSpaces(item.Text)}
+		case EndKeys:
+			if !inKeys {
+				return nil, nil, &Error{Rule: name, Offset: item.Offset + leadingSpaces(item.Text)}
+			}
+			return rs, tl, nil
+		}
+		parse := parseRule
+		if isExpr(item.Text) {
+			parse = parseExprRule
+		}
+		r, err := parse(item)
+		if err != nil {
+			return nil, nil, err
+		}
+		if r.Name == Msg || r.Name == Code {
+			msg, code := &rs.Message, &rs.Code
+			if n := len(rs.Rules); n > 0 {
+				msg, code = &rs.Rules[n-1].Message, &rs.Rules[n-1].Code
+			}
+			if r.Name == Msg {
+				*msg = r.Param
+			} else {
+				*code = r.Param
+			}
+			continue
+		}
+		rs.Rules = append(rs.Rules, r)
+	}
+	if inKeys {
+		// missing endkeys, reported at the end of the tag
+		return nil, nil, &Error{Rule: EndKeys, Offset: end}
+	}
+	return rs, nil, nil
+}
+
+// parseRule parses a single item.
+func parseRule(item Item) (Rule, error) {
+	r := Rule{Offset: item.Offset + leadingSpaces(item.Text)}
+	v := strings.SplitN(item.Text, "=", 2)
+	r.Name = strings.Trim(v[0], " ")
+	if r.Name == "" {
+		return Rule{}, &Error{Offset: r.Offset}
+	}
+	r.ParamOffset = r.Offset + len(r.Name)
+	if len(v) > 1 {
+		r.ParamOffset = item.Offset + len(v[0]) + 1 + leadingSpaces(v[1])
+		r.Param = strings.Replace(strings.Trim(v[1], " "), `\,`, ",", -1)
+	}
+	return r, nil
+}
+
+// BareAlternatives reports whether param, the parameter of a rule outside
+// expressions, holds a | followed by a rule, as in the "3|len=2" of
+// "min=3|len=2". Such items are read as a single rule: alternatives are
+// only read within parentheses, as in "(min=3|len=2)".
+func BareAlternatives(param string) bool {
+	for i := 0; i < len(param); i++ {
+		if param[i] != '|' {
+			continue
+		}
+		rest := strings.TrimLeft(param[i+1:], " ")
+		if end := strings.IndexAny(rest, "=|"); end >= 0 {
+			rest = rest[:end]
+		}
+		if isRuleName(strings.TrimRight(rest, " ")) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRuleName reports whether s is a name rules can have.
+func isRuleName(s string) bool {
+	for i, c := range s {
+		switch {
+		case c == '_', 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
+
+// leadingSpaces returns the number of spaces s starts with.
+func leadingSpaces(s string) int {
+	return len(s) - len(strings.TrimLeft(s, " "))
+}
+
+// parseExprRule parses an item holding an expression.
+func parseExprRule(item Item) (Rule, error) {
+	p := &exprParser{s: item.Text, base: item.Offset}
+	e, err := p.parseOr()
+	if err != nil {
+		return Rule{}, err
+	}
+	if p.skipSpaces(); p.pos < len(p.s) {
+		return Rule{}, &Error{Rule: p.s[p.pos : p.pos+1], Offset: p.base + p.pos}
+	}
+	if e.Op == Leaf {
+		// only parentheses around a rule
+		return e.Rule, nil
+	}
+	return Rule{Offset: e.Offset, ParamOffset: e.Offset, Expr: e}, nil
+}
+
+// exprParser parses the expression of an item by recursive descent.
+type exprParser struct {
+	s    string // text of the item
+	pos  int    // position within s
+	base int    // offset of s within the tag
+}
+
+func (p *exprParser) skipSpaces() {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// peek returns the next byte that is not a space, or 0 at the end.
+func (p *exprParser) peek() byte {
+	if p.skipSpaces(); p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+// node returns an expression of op starting at start.
+func (p *exprParser) node(op Op, start int, args []*Expr) *Expr {
+	return &Expr{Op: op, Args: args, Text: strings.Trim(p.s[start:p.pos], " "), Offset: p.base + start}
+}
+
+// parseOr parses alternatives separated by |.
+func (p *exprParser) parseOr() (*Expr, error) {
+	p.skipSpaces()
+	start := p.pos
+	e, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	args := []*Expr{e}
+	for p.peek() == '|' {
+		p.pos++
+		if e, err = p.parseTerm(); err != nil {
+			return nil, err
+		}
+		args = append(args, e)
+	}
+	if len(args) == 1 {
+		return e, nil
+	}
+	return p.node(Or, start, args), nil
+}
+
+// parseTerm parses a negation, a group in parentheses or a rule.
+func (p *exprParser) parseTerm() (*Expr, error) {
+	c := p.peek()
+	start := p.pos
+	switch c {
+	case '!':
+		p.pos++
+		e, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		return p.node(Not, start, []*Expr{e}), nil
+	case '(':
+		p.pos++
+		var args []*Expr
+		for {
+			e, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, e)
+			if p.peek() != ',' {
+				break
+			}
+			p.pos++
+		}
+		if p.peek() != ')' {
+			return nil, &Error{Rule: "(", Offset: p.base + start}
+		}
+		p.pos++
+		if len(args) == 1 {
+			return args[0], nil
+		}
+		return p.node(And, start, args), nil
+	}
+	return p.parseLeaf()
+}
+
+// parseLeaf parses a single rule, whose parameter ends at an unescaped
+// comma, | or closing parenthesis outside of the parentheses it holds.
+func (p *exprParser) parseLeaf() (*Expr, error) {
+	p.skipSpaces()
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune("=|,()!", rune(p.s[p.pos])) {
+		p.pos++
+	}
+	r := Rule{Name: strings.Trim(p.s[start:p.pos], " "), Offset: p.base + start}
+	if r.Name == "" {
+		return nil, &Error{Offset: r.Offset}
+	}
+	if IsKeyword(r.Name) {
+		return nil, &Error{Rule: r.Name, Offset: r.Offset}
+	}
+	r.ParamOffset = r.Offset + len(r.Name)
+	if p.pos < len(p.s) && p.s[p.pos] == '=' {
+		p.pos++
+		from, depth := p.pos, 0
+	param:
+		for ; p.pos < len(p.s); p.pos++ {
+			switch p.s[p.pos] {
+			case '\\':
+				p.pos++
+			case '(':
+				depth++
+			case ')':
+				if depth == 0 {
+					break param
+				}
+				depth--
+			case '|', ',':
+				if depth == 0 {
+					break param
+				}
+			}
+		}
+		if p.pos > len(p.s) {
+			p.pos = len(p.s)
+		}
+		param := p.s[from:p.pos]
+		r.ParamOffset = p.base + from + leadingSpaces(param)
+		r.Param = exprUnescaper.Replace(strings.Trim(param, " "))
+	}
+	e := p.node(Leaf, start, nil)
+	e.Rule = r
+	return e, nil
+}
+
+// exprUnescaper unescapes the parameters of rules within expressions,
+// leaving escaped backslashes as they are.
+var exprUnescaper = strings.NewReplacer(`\\`, `\\`, `\,`, ",", `\|`, "|", `\(`, "(", `\)`, ")")
@@ -0,0 +1,400 @@
+// Package validator implements value validations
+//
+// Copyright 2014 Roberto Teixeira <robteix@robteix.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidateJSON calls the ValidateJSON method on the default validator.
+func ValidateJSON(v interface{}, s *Schema) error {
+	return defaultValidator.ValidateJSON(v, s)
+}
+
+// ValidateJSON validates v, a value decoded by encoding/json such as a
+// map[string]interface{} or a []interface{}, against the JSON Schema s,
+// usually read with json.Unmarshal. Numbers may be float64 values or, when
+// decoded with UseNumber, json.Numbers.
+//
+// The errors found are returned in an ErrorMap indexed by the path of the
+// values, as Validate does: the properties of objects are joined with dots
+// and array items are indexed, as in "orders[1].total", while the names and
+// values checked by propertyNames and additionalProperties are those of map
+// entries, as in "labels[env](key)" and "labels[env](value)". The errors of v
+// itself have an empty path. Each error is the one of the matching rule:
+// ErrZeroValue for a missing required property, ErrMin and ErrMax for
+// minimum, maximum and the keywords bounding lengths, ErrRegexp for pattern,
+// ErrType for type and ErrNot for not. enum and anyOf fail with an
+// AlternativesError holding, for enum, an ErrInvalid for each value and,
+// for anyOf, the errors of each schema. Keywords whose value cannot be used,
+// such as a pattern that does not compile, fail with ErrBadParameter. When
+// SetFieldErrors asks for them, the errors are *FieldErrors naming the
+// keyword that failed and wrapping those errors.
+//
+// The keywords supported are type, enum, required, properties,
+// additionalProperties, propertyNames, items, minimum, maximum, minLength,
+// maxLength, pattern, minItems, maxItems, minProperties, maxProperties,
+// allOf, anyOf, not and $ref to the $defs of s. Other keywords are ignored.
+// Patterns are run as RE2 regular expressions rather than ECMA 262 ones.
+func (mv *Validator) ValidateJSON(v interface{}, s *Schema) error {
+	jv := &jsonValidation{root: s, m: make(ErrorMap), fieldErrors: mv.config().useFieldErrors}
+	jv.validate(v, s, "", "")
+	if len(jv.m) > 0 {
+		return jv.m
+	}
+	return nil
+}
+
+// maxRefs is the number of $ref followed in a row, without going down
+// the value, past which the schema is assumed to loop.
+const maxRefs = 32
+
+// jsonValidation holds the state of a call to ValidateJSON.
+type jsonValidation struct {
+	// root is the schema holding the $defs references point to.
+	root *Schema
+	// m collects the errors found so far.
+	m ErrorMap
+	// refs is the number of $ref followed for the current value.
+	refs int
+	// fieldErrors is set when the errors are reported as *FieldErrors.
+	fieldErrors bool
+}
+
+// add adds the error of fe to the errors of its path.
+func (jv *jsonValidation) add(fe *FieldError) {
+	if jv.fieldErrors {
+		jv.m[fe.Path] = append(jv.m[fe.Path], fe)
+	} else {
+		jv.m[fe.Path] = append(jv.m[fe.Path], fe.Err)
+	}
+}
+
+// validate validates v against s. path is the path of v and name the
+// name of the property holding it.
+func (jv *jsonValidation) validate(v interface{}, s *Schema, path, name string) {
+	if s == nil {
+		return
+	}
+	fail := func(keyword string, param interface{}, err error) {
+		jv.add(&FieldError{
+			Path:     path,
+			Field:    name,
+			JSONName: name,
+			Rule:     keyword,
+			Param:    fmt.Sprint(param),
+			Value:    v,
+			Err:      err,
+		})
+	}
+
+	if s.Ref != "" {
+		if target := jv.resolve(s.Ref); target == nil || jv.refs >= maxRefs {
+			fail("$ref", s.Ref, ErrBadParameter)
+		} else {
+			jv.refs++
+			jv.validate(v, target, path, name)
+			jv.refs--
+		}
+	}
+	if len(s.Type) > 0 && !jsonTypeMatches(v, s.Type) {
+		// the other keywords would fail for the same reason
+		fail("type", strings.Join(s.Type, ","), ErrType)
+		return
+	}
+	if len(s.Enum) > 0 {
+		errs := make(AlternativesError, 0, len(s.Enum))
+		for _, e := range s.Enum {
+			if jsonEqual(v, e) {
+				errs = nil
+				break
+			}
+			errs = append(errs, &FieldError{Path: path, Field: name, JSONName: name, Rule: "const", Param: jsonText(e), Value: v, Err: ErrInvalid})
+		}
+		if errs != nil {
+			fail("enum", jsonText(s.Enum), errs)
+		}
+	}
+	for _, sub := range s.AllOf {
+		jv.validate(v, sub, path, name)
+	}
+	if len(s.AnyOf) > 0 {
+		jv.anyOf(v, s.AnyOf, path, name, fail)
+	}
+	if s.Not != nil && len(jv.sub(v, s.Not, path, name)) == 0 {
+		fail("not", jsonText(s.Not), ErrNot)
+	}
+
+	if n, ok := jsonNumber(v); ok {
+		bound := func(keyword string, limit json.Number, err error, out func(n, limit float64) bool) {
+			if limit == "" {
+				return
+			}
+			if l, perr := limit.Float64(); perr != nil {
+				fail(keyword, limit, ErrBadParameter)
+			} else if out(n, l) {
+				fail(keyword, limit, err)
+			}
+		}
+		bound("minimum", s.Minimum, ErrMin, func(n, l float64) bool { return n < l })
+		bound("maximum", s.Maximum, ErrMax, func(n, l float64) bool { return n > l })
+		return
+	}
+
+	length := func(minKeyword string, min *int64, maxKeyword string, max *int64, n int) {
+		if min != nil && int64(n) < *min {
+			fail(minKeyword, *min, ErrMin)
+		}
+		if max != nil && int64(n) > *max {
+			fail(maxKeyword, *max, ErrMax)
+		}
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		str := rv.String()
+		length("minLength", s.MinLength, "maxLength", s.MaxLength, utf8.RuneCountInString(str))
+		if s.Pattern != "" {
+			if re, err := compileRegexp(s.Pattern); err != nil {
+				fail("pattern", s.Pattern, ErrBadParameter)
+			} else if !re.MatchString(str) {
+				fail("pattern", s.Pattern, ErrRegexp)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		length("minItems", s.MinItems, "maxItems", s.MaxItems, rv.Len())
+		if s.Items != nil {
+			for i := 0; i < rv.Len(); i++ {
+				jv.child(valueInterface(rv.Index(i)), s.Items, fmt.Sprintf("%s[%d]", path, i), name)
+			}
+		}
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return
+		}
+		length("minProperties", s.MinProperties, "maxProperties", s.MaxProperties, rv.Len())
+		for _, req := range s.Required {
+			if !rv.MapIndex(reflect.ValueOf(req).Convert(rv.Type().Key())).IsValid() {
+				p := joinPath(path, req)
+				jv.add(&FieldError{
+					Path:     p,
+					Field:    req,
+					JSONName: req,
+					Rule:     "required",
+					Err:      ErrZeroValue,
+				})
+			}
+		}
+		for _, key := range rv.MapKeys() {
+			k := key.String()
+			jv.child(k, s.PropertyNames, fmt.Sprintf("%s[%s](key)", path, k), name)
+			if ps, found := s.Properties[k]; found {
+				jv.child(valueInterface(rv.MapIndex(key)), ps, joinPath(path, k), k)
+			} else {
+				jv.child(valueInterface(rv.MapIndex(key)), s.AdditionalProperties, fmt.Sprintf("%s[%s](value)", path, k), name)
+			}
+		}
+	}
+}
+
+// child validates v, held by the value being validated, against s.
+func (jv *jsonValidation) child(v interface{}, s *Schema, path, name string) {
+	refs := jv.refs
+	jv.refs = 0
+	jv.validate(v, s, path, name)
+	jv.refs = refs
+}
+
+// resolve returns the schema of the $defs of the root schema that ref
+// points to, or nil.
+func (jv *jsonValidation) resolve(ref string) *Schema {
+	switch {
+	case ref == "#":
+		return jv.root
+	case strings.HasPrefix(ref, "#/$defs/"):
+		name := strings.NewReplacer("~1", "/", "~0", "~").Replace(ref[len("#/$defs/"):])
+		return jv.root.Defs[name]
+	}
+	return nil
+}
+
+// sub returns the errors of v against s as *FieldErrors, without adding
+// them to jv.
+func (jv *jsonValidation) sub(v interface{}, s *Schema, path, name string) ErrorMap {
+	sub := &jsonValidation{root: jv.root, m: make(ErrorMap), refs: jv.refs, fieldErrors: true}
+	sub.validate(v, s, path, name)
+	return sub.m
+}
+
+// anyOf validates v against the schemas of anyOf. When v matches none,
+// the errors found against the only schema allowing the type of v are
+// reported, as for the anyOf of a nullable reference. Otherwise fail is
+// called with an AlternativesError holding the errors of each schema.
+func (jv *jsonValidation) anyOf(v interface{}, schemas []*Schema, path, name string, fail func(string, interface{}, error)) {
+	var typed ErrorMap
+	n := 0
+	alts := make(AlternativesError, 0, len(schemas))
+	for _, s := range schemas {
+		m := jv.sub(v, s, path, name)
+		if len(m) == 0 {
+			return
+		}
+		if wrongType := len(m[path]) > 0 && errors.Is(m[path][0], ErrType); !wrongType {
+			typed = m
+			n++
+		}
+		alts = append(alts, flattenErrors(m))
+	}
+	if n != 1 {
+		fail("anyOf", len(schemas), alts)
+		return
+	}
+	for _, errs := range typed {
+		for _, err := range errs {
+			jv.add(err.(*FieldError))
+		}
+	}
+}
+
+// flattenErrors returns the errors of m in a single ErrorArray, sorted by
+// path, or the only error of m.
+func flattenErrors(m ErrorMap) error {
+	paths := make([]string, 0, len(m))
+	for p := range m {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	var errs ErrorArray
+	for _, p := range paths {
+		errs = append(errs, m[p]...)
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errs
+}
+
+// jsonTypeMatches reports whether v has one of the JSON types named
+// by types.
+func jsonTypeMatches(v interface{}, types SchemaType) bool {
+	for _, t := range types {
+		var ok bool
+		switch t {
+		case "null":
+			ok = v == nil
+		case "boolean":
+			_, ok = v.(bool)
+		case "number":
+			_, ok = jsonNumber(v)
+		case "integer":
+			n, isNumber := jsonNumber(v)
+			ok = isNumber && n == math.Trunc(n) && !math.IsInf(n, 0)
+		case "string":
+			_, isNumber := jsonNumber(v)
+			ok = !isNumber && reflect.ValueOf(v).Kind() == reflect.String
+		case "array":
+			k := reflect.ValueOf(v).Kind()
+			ok = k == reflect.Slice || k == reflect.Array
+		case "object":
+			rv := reflect.ValueOf(v)
+			ok = rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonNumber returns the value of v if it is a number, which may be a
+// json.Number.
+func jsonNumber(v interface{}) (float64, bool) {
+	if n, ok := v.(json.Number); ok {
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// jsonEqual reports whether a and b are the same JSON value, numbers
+// being compared by value whatever their Go type.
+func jsonEqual(a, b interface{}) bool {
+	if na, ok := jsonNumber(a); ok {
+		nb, ok := jsonNumber(b)
+		return ok && na == nb
+	}
+	if _, ok := jsonNumber(b); ok {
+		return false
+	}
+	ra, rb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !ra.IsValid() || !rb.IsValid() {
+		return ra.IsValid() == rb.IsValid()
+	}
+	switch ra.Kind() {
+	case reflect.Slice, reflect.Array:
+		if k := rb.Kind(); (k != reflect.Slice && k != reflect.Array) || ra.Len() != rb.Len() {
+			return false
+		}
+		for i := 0; i < ra.Len(); i++ {
+			if !jsonEqual(ra.Index(i).Interface(), rb.Index(i).Interface()) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if rb.Kind() != reflect.Map || ra.Type().Key() != rb.Type().Key() || ra.Len() != rb.Len() {
+			return false
+		}
+		for _, k := range ra.MapKeys() {
+			vb := rb.MapIndex(k)
+			if !vb.IsValid() || !jsonEqual(ra.MapIndex(k).Interface(), vb.Interface()) {
+				return false
+			}
+		}
+		return true
+	case reflect.String:
+		return rb.Kind() == reflect.String && ra.String() == rb.String()
+	case reflect.Bool:
+		return rb.Kind() == reflect.Bool && ra.Bool() == rb.Bool()
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// jsonText returns v encoded as JSON, for the parameters of errors.
+func jsonText(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
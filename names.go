@@ -0,0 +1,151 @@
+// Package validator implements value validations
+//
+// Copyright 2014 Roberto Teixeira <robteix@robteix.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validator
+
+import (
+	"reflect"
+	"strings"
+)
+
+// SetNameFunc calls the SetNameFunc method on the default validator.
+func SetNameFunc(f func(reflect.StructField) string) {
+	defaultValidator.SetNameFunc(f)
+}
+
+// SetNameFunc sets the function naming struct fields in the paths of
+// errors, which are the keys of ErrorMaps, and in the Name of FieldErrors.
+// When f returns "", the Go field name is used, unless SetFlattenEmbedded
+// is set for embedded structs. A nil f, the default, names fields after
+// their Go name. JSONFieldName, YAMLFieldName, XMLFieldName, FormFieldName
+// and ProtobufFieldName are ready to use, and NameTag reads other tags.
+// It replaces the json names set with SetPrintJSON.
+func (mv *Validator) SetNameFunc(f func(reflect.StructField) string) {
+	mv.update(func(cfg *config) {
+		cfg.nameFunc = f
+		cfg.printJSON = false
+	})
+}
+
+// WithNameFunc creates a new Validator with the new name function.
+func WithNameFunc(f func(reflect.StructField) string) *Validator {
+	return defaultValidator.WithNameFunc(f)
+}
+
+// WithNameFunc creates a new Validator with the new name function. It is
+// useful to chain-call with Validate:
+// validator.WithNameFunc(validator.YAMLFieldName).Validate(t)
+func (mv *Validator) WithNameFunc(f func(reflect.StructField) string) *Validator {
+	v := mv.copy()
+	v.SetNameFunc(f)
+	return v
+}
+
+// SetFlattenEmbedded calls the SetFlattenEmbedded method on the default validator.
+func SetFlattenEmbedded(flattenEmbedded bool) {
+	defaultValidator.SetFlattenEmbedded(flattenEmbedded)
+}
+
+// SetFlattenEmbedded sets whether the fields of embedded structs the
+// function set with SetNameFunc gives no name are named as fields of the
+// embedding struct, as encoding/json does. With JSONFieldName, an untagged
+// embedded struct E holding a field X then reports its errors under "X"
+// instead of "E.X".
+func (mv *Validator) SetFlattenEmbedded(flattenEmbedded bool) {
+	mv.update(func(cfg *config) {
+		cfg.flattenEmbedded = flattenEmbedded
+	})
+}
+
+// WithFlattenEmbedded creates a new Validator with flattenEmbedded set to new value.
+func WithFlattenEmbedded(flattenEmbedded bool) *Validator {
+	return defaultValidator.WithFlattenEmbedded(flattenEmbedded)
+}
+
+// WithFlattenEmbedded creates a new Validator with flattenEmbedded set to new value.
+func (mv *Validator) WithFlattenEmbedded(flattenEmbedded bool) *Validator {
+	v := mv.copy()
+	v.SetFlattenEmbedded(flattenEmbedded)
+	return v
+}
+
+// NameTag returns a name function, for SetNameFunc, giving the names of
+// struct fields by their tag of the given name, written as json tags are:
+// the name comes before any comma and "-" gives none.
+func NameTag(name string) func(reflect.StructField) string {
+	return func(fieldDef reflect.StructField) string {
+		return parseName(fieldDef.Tag.Get(name))
+	}
+}
+
+// JSONFieldName returns the name given to a field by its json tag.
+func JSONFieldName(fieldDef reflect.StructField) string {
+	return parseName(fieldDef.Tag.Get("json"))
+}
+
+// YAMLFieldName returns the name given to a field by its yaml tag, or its
+// lowercased Go name as YAML encoders do. Inlined fields have no name.
+func YAMLFieldName(fieldDef reflect.StructField) string {
+	tag := fieldDef.Tag.Get("yaml")
+	if tag == "-" {
+		return ""
+	}
+	parts := strings.Split(tag, ",")
+	for _, flag := range parts[1:] {
+		if flag == "inline" {
+			return ""
+		}
+	}
+	if parts[0] != "" {
+		return parts[0]
+	}
+	return strings.ToLower(fieldDef.Name)
+}
+
+// XMLFieldName returns the name given to a field by its xml tag, without
+// its namespace, such as "item" for `xml:"http://example.com item,attr"`.
+// Nested names such as "a>b" are kept as they are.
+func XMLFieldName(fieldDef reflect.StructField) string {
+	name := parseName(fieldDef.Tag.Get("xml"))
+	if i := strings.LastIndexByte(name, ' '); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// FormFieldName returns the name given to a field by its form tag.
+func FormFieldName(fieldDef reflect.StructField) string {
+	return parseName(fieldDef.Tag.Get("form"))
+}
+
+// ProtobufFieldName returns the JSON name of a field of a struct generated
+// by protoc-gen-go, given by the json= item of its protobuf tag, or else by
+// its name= item. Oneof fields are named by their protobuf_oneof tag.
+func ProtobufFieldName(fieldDef reflect.StructField) string {
+	if name := fieldDef.Tag.Get("protobuf_oneof"); name != "" {
+		return name
+	}
+	var name string
+	for _, item := range strings.Split(fieldDef.Tag.Get("protobuf"), ",") {
+		switch {
+		case strings.HasPrefix(item, "json="):
+			return item[len("json="):]
+		case strings.HasPrefix(item, "name="):
+			name = item[len("name="):]
+		}
+	}
+	return name
+}
@@ -0,0 +1,269 @@
+// Package validator implements value validations
+//
+// Copyright 2014 Roberto Teixeira <robteix@robteix.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validator
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// OpenAPIComponents holds the schemas of the components section of an
+// OpenAPI 3.0 document, indexed by their name.
+type OpenAPIComponents struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// OpenAPI calls the OpenAPI method on the default validator.
+func OpenAPI(types ...interface{}) (*OpenAPIComponents, error) {
+	return defaultValidator.OpenAPI(types...)
+}
+
+// OpenAPI returns the OpenAPI 3.0 schemas of types, and of the named
+// struct types their fields refer to, with the rules of their tags as
+// constraint keywords. Each type is given either as a reflect.Type or as a
+// value of the type, and must be a named type.
+//
+// The schemas are those written by JSONSchema, turned into the OpenAPI 3.0
+// dialect: references point to #/components/schemas, pointers, slices and
+// maps are nullable, byte slices have the byte format, their lengths
+// bounded as JSONSchema does, and time.Time values the date-time one.
+// Properties are named after the json tags of the fields and the fields of
+// embedded structs are flattened into those of the struct.
+// propertyNames, which OpenAPI 3.0 does not have, is left out.
+func (mv *Validator) OpenAPI(types ...interface{}) (*OpenAPIComponents, error) {
+	b := &schemaBuilder{cfg: mv.config(), defs: map[string]*Schema{}, names: map[planKey]string{}}
+	for _, typ := range types {
+		t, ok := typ.(reflect.Type)
+		if !ok {
+			t = reflect.TypeOf(typ)
+		}
+		if t == nil {
+			return nil, ErrUnsupported
+		}
+		t = indirectType(t)
+		if t.Name() == "" {
+			return nil, ErrUnsupported
+		}
+		s, _, err := b.valueSchema(t, nil)
+		if err != nil {
+			return nil, err
+		}
+		if s == nil {
+			return nil, ErrUnsupported
+		}
+		if t.Kind() != reflect.Struct {
+			// struct types are in defs already
+			b.defs[b.defName(t)] = s
+		}
+	}
+	c := &OpenAPIComponents{Schemas: make(map[string]*Schema, len(b.defs))}
+	for name, s := range b.defs {
+		c.Schemas[name] = openAPISchema(s)
+	}
+	return c, nil
+}
+
+// openAPISchema returns a copy of s in the OpenAPI 3.0 dialect.
+func openAPISchema(s *Schema) *Schema {
+	if s == nil {
+		return nil
+	}
+	o := *s
+	o.Schema, o.Defs, o.PropertyNames = "", nil, nil
+	if strings.HasPrefix(o.Ref, "#/$defs/") {
+		o.Ref = "#/components/schemas/" + o.Ref[len("#/$defs/"):]
+	}
+	if o.ContentEncoding == "base64" {
+		o.ContentEncoding, o.Format = "", "byte"
+	}
+	o.Extra = make(map[string]interface{}, len(s.Extra)+1)
+	for k, v := range s.Extra {
+		o.Extra[k] = v
+	}
+
+	// OpenAPI 3.0 has a single type and a nullable keyword
+	var nullable bool
+	o.Type = nil
+	for _, t := range s.Type {
+		if t == "null" {
+			nullable = true
+		} else {
+			o.Type = append(o.Type, t)
+		}
+	}
+	o.AnyOf = nil
+	for _, sub := range s.AnyOf {
+		if len(sub.Type) == 1 && sub.Type[0] == "null" && sub.Ref == "" {
+			nullable = true
+		} else {
+			o.AnyOf = append(o.AnyOf, openAPISchema(sub))
+		}
+	}
+	if nullable && len(o.AnyOf) == 1 && o.AnyOf[0].Ref != "" {
+		// keywords next to $ref are ignored, allOf keeps nullable
+		o.AllOf, o.AnyOf = append([]*Schema{o.AnyOf[0]}, o.AllOf...), nil
+	}
+	if nullable {
+		o.Extra["nullable"] = true
+	}
+	if len(o.Extra) == 0 {
+		o.Extra = nil
+	}
+
+	o.AdditionalProperties = openAPISchema(s.AdditionalProperties)
+	o.Items = openAPISchema(s.Items)
+	o.Not = openAPISchema(s.Not)
+	if s.Properties != nil {
+		o.Properties = make(map[string]*Schema, len(s.Properties))
+		for k, p := range s.Properties {
+			o.Properties[k] = openAPISchema(p)
+		}
+	}
+	if len(s.AllOf) > 0 {
+		allOf := o.AllOf[:len(o.AllOf)-len(s.AllOf)]
+		for _, sub := range s.AllOf {
+			allOf = append(allOf, openAPISchema(sub))
+		}
+		o.AllOf = allOf
+	}
+	return &o
+}
+
+// JSON returns the components as the JSON document
+// {"components": {"schemas": {...}}}.
+func (c *OpenAPIComponents) JSON() ([]byte, error) {
+	return json.MarshalIndent(map[string]*OpenAPIComponents{"components": c}, "", "  ")
+}
+
+// YAML returns the components as a YAML document with a
+// top-level components key.
+func (c *OpenAPIComponents) YAML() ([]byte, error) {
+	doc, err := json.Marshal(map[string]*OpenAPIComponents{"components": c})
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(doc))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	writeYAML(&b, v, 0)
+	return b.Bytes(), nil
+}
+
+// writeYAML writes v, a value decoded from JSON, as YAML in block style,
+// indent being that of the current mapping or sequence.
+func writeYAML(b *bytes.Buffer, v interface{}, indent int) {
+	pad := strings.Repeat("  ", indent)
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for i, k := range keys {
+			if i > 0 || b.Len() == 0 || b.Bytes()[b.Len()-1] == '\n' {
+				b.WriteString(pad)
+			}
+			b.WriteString(yamlScalar(k))
+			b.WriteByte(':')
+			writeYAMLValue(b, v[k], indent+1)
+		}
+	case []interface{}:
+		for i, e := range v {
+			if i > 0 || b.Len() == 0 || b.Bytes()[b.Len()-1] == '\n' {
+				b.WriteString(pad)
+			}
+			b.WriteString("- ")
+			if m, ok := e.(map[string]interface{}); ok && len(m) > 0 {
+				// the first key follows the dash
+				writeYAML(b, m, indent+1)
+				continue
+			}
+			writeYAMLValue(b, e, indent+1)
+		}
+	}
+}
+
+// writeYAMLValue writes the value of a mapping key or sequence item,
+// after the key or the dash.
+func writeYAMLValue(b *bytes.Buffer, v interface{}, indent int) {
+	switch e := v.(type) {
+	case map[string]interface{}:
+		if len(e) == 0 {
+			b.WriteString(" {}\n")
+			return
+		}
+		b.WriteByte('\n')
+		writeYAML(b, e, indent)
+		return
+	case []interface{}:
+		if len(e) == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+		b.WriteByte('\n')
+		writeYAML(b, e, indent)
+		return
+	}
+	if last := b.Bytes()[b.Len()-1]; last != ' ' {
+		b.WriteByte(' ')
+	}
+	switch e := v.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		if e {
+			b.WriteString("true")
+		} else {
+			b.WriteString("false")
+		}
+	case json.Number:
+		b.WriteString(e.String())
+	case string:
+		b.WriteString(yamlScalar(e))
+	}
+	b.WriteByte('\n')
+}
+
+// yamlPlain matches the strings written without quotes.
+var yamlPlain = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$./-]*$`)
+
+// yamlScalar returns s as a YAML scalar, quoted unless it is a plain
+// word that YAML would not read as something else.
+func yamlScalar(s string) string {
+	switch strings.ToLower(s) {
+	case "true", "false", "null", "yes", "no", "on", "off", "y", "n":
+	default:
+		if yamlPlain.MatchString(s) {
+			return s
+		}
+	}
+	// JSON strings are valid double-quoted YAML scalars
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,144 @@
+// Package validator implements value validations
+//
+// Copyright 2014 Roberto Teixeira <robteix@robteix.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validator
+
+import (
+	"reflect"
+	"sync"
+)
+
+// planCache holds the compiled validation plans of a Validator, indexed
+// by struct type and scope. A Validator gets a new, empty cache whenever
+// its configuration changes so stale plans are never used.
+type planCache struct {
+	structs sync.Map // map[planKey]*structPlan
+}
+
+// planKey identifies the plan of struct type st within scope, the type
+// whose rules registered through nested paths apply to st, if any.
+type planKey struct {
+	st, scope reflect.Type
+}
+
+func newPlanCache() *planCache {
+	return &planCache{}
+}
+
+// structPlan is the compiled form of a struct type: every field that
+// needs to be looked at, with its tags already parsed.
+type structPlan struct {
+	fields []fieldPlan
+	// generated is set when the ValidateFields method of the
+	// struct is used in place of the rules of its fields.
+	generated bool
+}
+
+// fieldPlan describes how a single struct field is validated.
+type fieldPlan struct {
+	// index of the field within its struct.
+	index int
+	// name of the field as used in error keys, empty for an
+	// embedded struct whose fields are named as those of its parent.
+	name string
+	// field and jsonName are the Go and json names of the
+	// field, reported in FieldErrors.
+	field    string
+	jsonName string
+	// label is the label of the field, if any.
+	label string
+	// rules are the parsed rules of the field tag, if any.
+	rules *ruleSet
+	// err is returned instead of running tags when the tag
+	// could not be parsed or the field cannot be validated.
+	err error
+	// recurse is false when the field type can never contain
+	// a struct to validate, so the value does not need walking.
+	recurse bool
+}
+
+// structPlan returns the compiled plan for struct type st within scope,
+// building and caching it on first use.
+func (cfg *config) structPlan(st, scope reflect.Type) *structPlan {
+	k := planKey{st, scope}
+	if p, ok := cfg.plans.structs.Load(k); ok {
+		return p.(*structPlan)
+	}
+	p, _ := cfg.plans.structs.LoadOrStore(k, cfg.compileStruct(st, scope))
+	return p.(*structPlan)
+}
+
+// compileStruct builds the validation plan of struct type st within scope.
+func (cfg *config) compileStruct(st, scope reflect.Type) *structPlan {
+	nfields := st.NumField()
+	p := &structPlan{
+		fields: make([]fieldPlan, 0, nfields),
+		// the methods do not know about registered rules
+		generated: cfg.useGenerated() && hasFieldsValidator(st) && scope == nil &&
+			cfg.registered[registeredKey{st, st}] == nil,
+	}
+	for i := 0; i < nfields; i++ {
+		fieldDef := st.Field(i)
+		tag, merged := cfg.fieldTags(st, scope, fieldDef)
+		if tag == "-" {
+			continue
+		}
+		// ignore private structs unless Anonymous
+		if !fieldDef.Anonymous && fieldDef.PkgPath != "" {
+			continue
+		}
+		fp := fieldPlan{
+			index:    i,
+			name:     cfg.fieldName(fieldDef),
+			field:    fieldDef.Name,
+			jsonName: jsonName(fieldDef),
+			label:    cfg.fieldLabel(fieldDef),
+			recurse:  cfg.mayRecurse(fieldDef.Type),
+		}
+		if tag != "" {
+			if fieldDef.PkgPath != "" {
+				fp.err = ErrCannotValidate
+			} else {
+				fp.rules, fp.err = cfg.parseFieldTags(tag, merged)
+				if te, ok := fp.err.(*TagError); ok {
+					te.Type, te.Field = st, fieldDef.Name
+				}
+			}
+		}
+		p.fields = append(p.fields, fp)
+	}
+	return p
+}
+
+// mayRecurse reports whether values of type t may hold a struct, or a
+// Validatable value when their methods are called, that
+// deepValidateCollection would need to validate.
+func (cfg *config) mayRecurse(t reflect.Type) bool {
+	if cfg.callValidate && selfValidating(t) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return cfg.mayRecurse(t.Elem())
+	case reflect.Struct, reflect.Interface:
+		return true
+	case reflect.Array, reflect.Slice:
+		return cfg.mayRecurse(t.Elem())
+	case reflect.Map:
+		return cfg.mayRecurse(t.Key()) || cfg.mayRecurse(t.Elem())
+	}
+	return false
+}
@@ -0,0 +1,428 @@
+// Package validator implements value validations
+//
+// Copyright 2014 Roberto Teixeira <robteix@robteix.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validator
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/validator.v2/internal/tagsyntax"
+)
+
+// registeredRules holds the rules registered for a struct field.
+type registeredRules struct {
+	// tag holds the rules, written as in a struct tag.
+	tag string
+	// merge is set when the rules are added to those of the
+	// field tag instead of replacing them.
+	merge bool
+}
+
+// RegisterRules calls the RegisterRules method on the default validator.
+func RegisterRules(typ interface{}, rules map[string]string) error {
+	return defaultValidator.RegisterRules(typ, rules)
+}
+
+// RegisterRules sets the rules of fields of a struct type, in place of
+// their tags, for types whose fields cannot be tagged. typ is either a
+// reflect.Type or a value of the type. rules maps the fields to their
+// rules, written as in a tag:
+//
+//	validator.RegisterRules(pb.User{}, map[string]string{
+//		"Name":        "nonzero,max=40",
+//		"Address.Zip": "len=5",
+//	})
+//
+// Fields of nested structs are named by their dotted path, going through
+// pointers and the elements of slices, arrays and maps. Their rules are
+// set on the struct type declaring the field, but only apply to the values
+// of that type found while walking a value of type typ, where they take
+// precedence over the rules registered for the type itself. The rules "-"
+// skip the field, as the tag "-" does.
+// Unknown or unexported fields are reported with ErrUnknownField and no
+// rule is registered. Rules registered again for the same field replace
+// the previous ones.
+func (mv *Validator) RegisterRules(typ interface{}, rules map[string]string) error {
+	return mv.registerRules(typ, rules, false)
+}
+
+// MergeRules calls the MergeRules method on the default validator.
+func MergeRules(typ interface{}, rules map[string]string) error {
+	return defaultValidator.MergeRules(typ, rules)
+}
+
+// MergeRules is like RegisterRules but keeps the rules of the field tags,
+// the registered rules running after them.
+func (mv *Validator) MergeRules(typ interface{}, rules map[string]string) error {
+	return mv.registerRules(typ, rules, true)
+}
+
+// registeredKey indexes the rules registered for the fields of struct
+// type st through the paths of type root, st itself for its own fields.
+type registeredKey struct {
+	root, st reflect.Type
+}
+
+// registeredField is a field named in the rules given to RegisterRules.
+type registeredField struct {
+	key   registeredKey
+	name  string
+	rules registeredRules
+}
+
+func (mv *Validator) registerRules(typ interface{}, rules map[string]string, merge bool) error {
+	t, ok := typ.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(typ)
+	}
+	if t == nil || indirectType(t).Kind() != reflect.Struct {
+		return ErrUnsupported
+	}
+	root := indirectType(t)
+	fields := make([]registeredField, 0, len(rules))
+	for path, tag := range rules {
+		st, name, err := fieldOwner(t, path)
+		if err != nil {
+			return err
+		}
+		fields = append(fields, registeredField{registeredKey{root, st}, name, registeredRules{tag: tag, merge: merge}})
+	}
+	mv.update(func(cfg *config) {
+		// the maps are shared with older snapshots
+		registered := make(map[registeredKey]map[string]registeredRules, len(cfg.registered)+1)
+		for k, m := range cfg.registered {
+			registered[k] = m
+		}
+		scopes := cfg.ruleScopes
+		for _, f := range fields {
+			m := make(map[string]registeredRules, len(registered[f.key])+1)
+			for name, r := range registered[f.key] {
+				m[name] = r
+			}
+			m[f.name] = f.rules
+			registered[f.key] = m
+			if f.key.root != f.key.st && !scopes[root] {
+				scopes = make(map[reflect.Type]bool, len(cfg.ruleScopes)+1)
+				for st := range cfg.ruleScopes {
+					scopes[st] = true
+				}
+				scopes[root] = true
+			}
+		}
+		cfg.registered, cfg.ruleScopes = registered, scopes
+	})
+	return nil
+}
+
+// enterScope returns scopes, the struct types being walked that have
+// rules registered through nested paths, with st added if it has some.
+func (cfg *config) enterScope(scopes []reflect.Type, st reflect.Type) []reflect.Type {
+	if !cfg.ruleScopes[st] {
+		return scopes
+	}
+	return append(scopes[:len(scopes):len(scopes)], st)
+}
+
+// ruleScope returns the outermost of scopes having rules registered
+// through nested paths for the fields of struct type st, or nil.
+func (cfg *config) ruleScope(scopes []reflect.Type, st reflect.Type) reflect.Type {
+	for _, root := range scopes {
+		if root != st && cfg.registered[registeredKey{root, st}] != nil {
+			return root
+		}
+	}
+	return nil
+}
+
+// fieldOwner returns the struct type declaring the field named by the
+// dotted path from type t, and the name of the field.
+func fieldOwner(t reflect.Type, path string) (reflect.Type, string, error) {
+	names := strings.Split(path, ".")
+	for i, name := range names {
+		t = structElem(t)
+		if t.Kind() != reflect.Struct {
+			return nil, "", fmt.Errorf("%w: %s", ErrUnknownField, path)
+		}
+		f, ok := t.FieldByName(name)
+		if !ok || f.PkgPath != "" {
+			return nil, "", fmt.Errorf("%w: %s", ErrUnknownField, path)
+		}
+		// promoted fields belong to the embedded struct declaring them
+		for _, index := range f.Index[:len(f.Index)-1] {
+			t = indirectType(t.Field(index).Type)
+		}
+		if i == len(names)-1 {
+			return t, f.Name, nil
+		}
+		t = f.Type
+	}
+	panic("unreachable")
+}
+
+// structElem dereferences pointers and the elements of arrays, slices
+// and maps, which hold the values the fields of a path are looked up in.
+func structElem(t reflect.Type) reflect.Type {
+	for {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Array, reflect.Slice, reflect.Map:
+			t = t.Elem()
+		default:
+			return t
+		}
+	}
+}
+
+// fieldTags returns the tag giving the rules of field f of struct type st,
+// taking into account the rules registered for st and, when scope is not
+// nil, those registered for it through the paths of scope. It also
+// returns the registered rules to merge with the tag, if any.
+func (cfg *config) fieldTags(st, scope reflect.Type, f reflect.StructField) (tag, merged string) {
+	tag = f.Tag.Get(cfg.tagName)
+	r, found := cfg.registered[registeredKey{scope, st}][f.Name]
+	if !found {
+		r, found = cfg.registered[registeredKey{st, st}][f.Name]
+	}
+	switch {
+	case !found:
+		return tag, ""
+	case !r.merge || r.tag == "-" || tag == "" || tag == "-":
+		return r.tag, ""
+	case r.tag == "":
+		return tag, ""
+	}
+	return tag, r.tag
+}
+
+// parseFieldTags parses the rules of tag followed by those of merged.
+// The rules of merged are reported as if they followed tag after a comma.
+func (cfg *config) parseFieldTags(tag, merged string) (*ruleSet, error) {
+	if merged == "" {
+		return cfg.parseTags(tag)
+	}
+	src := tag + "," + merged
+	a, err := tagsyntax.Parse(tag)
+	if err != nil {
+		e := err.(*tagsyntax.Error)
+		return nil, unknownTag(src, e.Rule, e.Offset)
+	}
+	b, err := tagsyntax.Parse(merged)
+	if err != nil {
+		e := err.(*tagsyntax.Error)
+		return nil, unknownTag(src, e.Rule, len(tag)+1+e.Offset)
+	}
+	shiftRuleSet(b, len(tag)+1)
+	return cfg.resolveRuleSet(mergeRuleSets(a, b), src)
+}
+
+// shiftRuleSet moves the offsets of the rules of rs by n bytes.
+func shiftRuleSet(rs *tagsyntax.RuleSet, n int) {
+	if rs == nil {
+		return
+	}
+	for i := range rs.Rules {
+		rs.Rules[i].Offset += n
+		rs.Rules[i].ParamOffset += n
+		shiftExpr(rs.Rules[i].Expr, n)
+	}
+	rs.Dive += n
+	shiftRuleSet(rs.Keys, n)
+	shiftRuleSet(rs.Elems, n)
+}
+
+// shiftExpr moves the offsets of the rules of e by n bytes.
+func shiftExpr(e *tagsyntax.Expr, n int) {
+	if e == nil {
+		return
+	}
+	e.Offset += n
+	if e.Op == tagsyntax.Leaf {
+		e.Rule.Offset += n
+		e.Rule.ParamOffset += n
+	}
+	for _, a := range e.Args {
+		shiftExpr(a, n)
+	}
+}
+
+// pushMessages gives the message and code of rs to its rules
+// without their own.
+func pushMessages(rs *tagsyntax.RuleSet) {
+	for i := range rs.Rules {
+		if rs.Rules[i].Message == "" {
+			rs.Rules[i].Message = rs.Message
+		}
+		if rs.Rules[i].Code == "" {
+			rs.Rules[i].Code = rs.Code
+		}
+	}
+	rs.Message, rs.Code = "", ""
+}
+
+// mergeRuleSets returns the rules of a followed by those of b, level by
+// level: the rules of the keys and elements of b follow those of a.
+func mergeRuleSets(a, b *tagsyntax.RuleSet) *tagsyntax.RuleSet {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	// the messages given first only apply to the rules of their tag
+	pushMessages(a)
+	pushMessages(b)
+	rs := &tagsyntax.RuleSet{
+		Rules: append(append([]tagsyntax.Rule{}, a.Rules...), b.Rules...),
+		Keys:  mergeRuleSets(a.Keys, b.Keys),
+		Elems: mergeRuleSets(a.Elems, b.Elems),
+		Dive:  a.Dive,
+	}
+	if a.Keys == nil && a.Elems == nil {
+		rs.Dive = b.Dive
+	}
+	return rs
+}
+
+// ReadRules reads rules for RegisterRules from r, grouped by the name of
+// their type. They are written either as a JSON object:
+//
+//	{"User": {"Name": "nonzero,max=40", "Address.Zip": "len=5"}}
+//
+// or in the following subset of YAML, where values may be quoted and
+// lines starting with # are comments:
+//
+//	User:
+//	  Name: nonzero,max=40
+//	  Address.Zip: "len=5"
+func ReadRules(r io.Reader) (map[string]map[string]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var rules map[string]map[string]string
+		if err := json.Unmarshal(trimmed, &rules); err != nil {
+			return nil, err
+		}
+		return rules, nil
+	}
+	return readYAMLRules(data)
+}
+
+// readYAMLRules reads rules written in the subset of YAML of ReadRules.
+func readYAMLRules(data []byte) (map[string]map[string]string, error) {
+	rules := make(map[string]map[string]string)
+	var fields map[string]string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimRight(sc.Text(), " \t\r")
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" || trimmed[0] == '#' {
+			continue
+		}
+		i := strings.IndexByte(trimmed, ':')
+		if i <= 0 {
+			return nil, fmt.Errorf("validator: rules line %d: missing colon", n)
+		}
+		key, value := strings.TrimSpace(trimmed[:i]), strings.TrimSpace(trimmed[i+1:])
+		if len(trimmed) == len(line) {
+			// a type name, starting a block of fields
+			if value != "" {
+				return nil, fmt.Errorf("validator: rules line %d: unexpected value for type %s", n, key)
+			}
+			fields = make(map[string]string)
+			rules[key] = fields
+			continue
+		}
+		if fields == nil {
+			return nil, fmt.Errorf("validator: rules line %d: field outside of a type", n)
+		}
+		value, err := unquoteRules(value)
+		if err != nil {
+			return nil, fmt.Errorf("validator: rules line %d: %v", n, err)
+		}
+		fields[key] = value
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// unquoteRules removes the double or single quotes around s, if any.
+func unquoteRules(s string) (string, error) {
+	switch {
+	case len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"':
+		return strconv.Unquote(s)
+	case len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'':
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	}
+	return s, nil
+}
+
+// LoadRules calls the LoadRules method on the default validator.
+func LoadRules(r io.Reader, types ...interface{}) error {
+	return defaultValidator.LoadRules(r, types...)
+}
+
+// LoadRules reads rules with ReadRules and registers them with
+// RegisterRules for the given types, each named in the rules by its
+// name, such as "User", or its package qualified name, such as "pb.User".
+// Rules given for other types are an error and no rule is registered.
+func (mv *Validator) LoadRules(r io.Reader, types ...interface{}) error {
+	rules, err := ReadRules(r)
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]reflect.Type, 2*len(types))
+	for _, typ := range types {
+		t, ok := typ.(reflect.Type)
+		if !ok {
+			t = reflect.TypeOf(typ)
+		}
+		if t == nil {
+			return ErrUnsupported
+		}
+		t = indirectType(t)
+		byName[t.Name()], byName[t.String()] = t, t
+	}
+	for name := range rules {
+		t, found := byName[name]
+		if !found {
+			return fmt.Errorf("validator: rules for type %s, which was not given", name)
+		}
+		if t.Kind() != reflect.Struct {
+			return ErrUnsupported
+		}
+		for path := range rules[name] {
+			if _, _, err := fieldOwner(t, path); err != nil {
+				return err
+			}
+		}
+	}
+	for name, fields := range rules {
+		if err := mv.RegisterRules(byName[name], fields); err != nil {
+			return err
+		}
+	}
+	return nil
+}
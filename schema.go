@@ -0,0 +1,776 @@
+// Package validator implements value validations
+//
+// Copyright 2014 Roberto Teixeira <robteix@robteix.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validator
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+
+	"gopkg.in/validator.v2/internal/tagsyntax"
+)
+
+// SchemaDialect is the JSON Schema dialect of the documents written
+// by JSONSchema.
+const SchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema document, or one of its subschemas. Only the
+// keywords the builtin rules map to have a field; other keywords, such as
+// those added by SchemaFuncs, go in Extra.
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+	Type                 SchemaType         `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	ContentEncoding      string             `json:"contentEncoding,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	PropertyNames        *Schema            `json:"propertyNames,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	Minimum              json.Number        `json:"minimum,omitempty"`
+	Maximum              json.Number        `json:"maximum,omitempty"`
+	MinLength            *int64             `json:"minLength,omitempty"`
+	MaxLength            *int64             `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinItems             *int64             `json:"minItems,omitempty"`
+	MaxItems             *int64             `json:"maxItems,omitempty"`
+	MinProperties        *int64             `json:"minProperties,omitempty"`
+	MaxProperties        *int64             `json:"maxProperties,omitempty"`
+	Not                  *Schema            `json:"not,omitempty"`
+	// Extra holds the other keywords of the schema, indexed by
+	// their name. They do not override the keywords above.
+	Extra map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON implements json.Marshaler, adding the keywords of Extra
+// to the others.
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	type plain Schema
+	b, err := json.Marshal((*plain)(s))
+	if err != nil || len(s.Extra) == 0 {
+		return b, err
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	for k, v := range s.Extra {
+		if _, found := m[k]; found {
+			continue
+		}
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		m[k] = raw
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, keeping the keywords
+// without a field in Extra. The boolean schemas true and false are read
+// as an empty schema and as one whose Not keyword is empty.
+func (s *Schema) UnmarshalJSON(b []byte) error {
+	switch string(bytes.TrimSpace(b)) {
+	case "true":
+		*s = Schema{}
+		return nil
+	case "false":
+		*s = Schema{Not: &Schema{}}
+		return nil
+	}
+	type plain Schema
+	if err := json.Unmarshal(b, (*plain)(s)); err != nil {
+		return err
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	for k, raw := range m {
+		if schemaKeywords[k] {
+			continue
+		}
+		var v interface{}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return err
+		}
+		if s.Extra == nil {
+			s.Extra = make(map[string]interface{})
+		}
+		s.Extra[k] = v
+	}
+	return nil
+}
+
+// schemaKeywords are the keywords having a field in Schema.
+var schemaKeywords = func() map[string]bool {
+	t := reflect.TypeOf(Schema{})
+	m := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if name := parseName(t.Field(i).Tag.Get("json")); name != "" {
+			m[name] = true
+		}
+	}
+	return m
+}()
+
+// SchemaType holds the value of the type keyword: the names of the JSON
+// types a value may have.
+type SchemaType []string
+
+// MarshalJSON implements json.Marshaler, writing a single type as a
+// string and several as an array.
+func (t SchemaType) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// UnmarshalJSON implements json.Unmarshaler, reading either a single
+// type or an array of them.
+func (t *SchemaType) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := json.Unmarshal(b, &name); err == nil {
+		*t = SchemaType{name}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(t))
+}
+
+// SchemaRule describes a rule being written to a Schema by a SchemaFunc.
+type SchemaRule struct {
+	// Schema is the schema of the value the rule applies to, where
+	// the rule adds its keywords.
+	Schema *Schema
+	// Type is the Go type of the value, pointers dereferenced.
+	Type reflect.Type
+	// Param is the parameter of the rule.
+	Param string
+	// Required is set by the rule when the value must be present,
+	// which is written to the required keyword of the struct
+	// holding it. Required pointers may not be null either.
+	Required bool
+}
+
+// SchemaFunc writes a rule as JSON Schema keywords. It returns
+// ErrBadParameter or ErrUnsupported when it cannot handle the parameter
+// or the type of the rule.
+type SchemaFunc func(r *SchemaRule) error
+
+// JSONSchema calls the JSONSchema method on the default validator.
+func JSONSchema(typ interface{}) (*Schema, error) {
+	return defaultValidator.JSONSchema(typ)
+}
+
+// JSONSchema returns a JSON Schema (draft 2020-12) document describing the
+// JSON encoding of the values of a type, with the rules of their tags.
+// typ is either a reflect.Type or a value of the type, such as a nil
+// pointer to it.
+//
+// Properties are named after the json tags of the fields, as SetPrintJSON
+// does, and the fields of embedded structs are inlined as encoding/json
+// does. Named struct types are described once in $defs. min, max and len
+// map to minimum and maximum for numbers, minLength and maxLength for
+// strings, minItems and maxItems for slices and arrays, and minProperties
+// and maxProperties for maps. Byte slices, written as base64 strings, get
+// the minLength and maxLength of their encoding, which is the same for the
+// lengths rounding up to a multiple of 3, so max=4 allows 6 bytes. regexp
+// maps to pattern, though JSON Schema patterns follow ECMA 262 rather than
+// RE2, and nonzero and nonnil make properties required. Rules diving into collections describe their items,
+// the values of maps and, for string keys, their names. Pointers, slices
+// and maps may be null unless required.
+//
+// Rules without a SchemaFunc, such as the cross-field rules, are left out.
+// Tags that cannot be parsed, and rules that cannot be written because of
+// their parameter or of the type of the field, are reported as *TagErrors.
+func (mv *Validator) JSONSchema(typ interface{}) (*Schema, error) {
+	t, ok := typ.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(typ)
+	}
+	if t == nil {
+		return nil, ErrUnsupported
+	}
+	b := &schemaBuilder{cfg: mv.config(), defs: map[string]*Schema{}, names: map[planKey]string{}}
+	s, _, err := b.valueSchema(indirectType(t), nil)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return nil, ErrUnsupported
+	}
+	s.Schema = SchemaDialect
+	if len(b.defs) > 0 {
+		s.Defs = b.defs
+	}
+	return s, nil
+}
+
+// SetSchemaFunc calls the SetSchemaFunc method on the default validator.
+func SetSchemaFunc(name string, sf SchemaFunc) error {
+	return defaultValidator.SetSchemaFunc(name, sf)
+}
+
+// SetSchemaFunc sets the function JSONSchema uses to write the rule of the
+// given name, usually one set with SetValidationFunc. Calling it with nil sf
+// leaves the rule out of schemas. Replacing a builtin rule with
+// SetValidationFunc removes its SchemaFunc too.
+func (mv *Validator) SetSchemaFunc(name string, sf SchemaFunc) error {
+	if name == "" {
+		return errors.New("name cannot be empty")
+	}
+	mv.update(func(cfg *config) {
+		if sf == nil {
+			delete(cfg.schemaFuncs, name)
+		} else {
+			cfg.schemaFuncs[name] = sf
+		}
+	})
+	return nil
+}
+
+// schemaBuilder holds the state of a call to JSONSchema.
+type schemaBuilder struct {
+	cfg *config
+	// defs are the schemas of the named struct types, indexed
+	// by their name in $defs.
+	defs map[string]*Schema
+	// names are the names in defs of the struct types, within
+	// the scopes of the registered rules.
+	names map[planKey]string
+	// scopes are the struct types being walked that have rules
+	// registered through nested paths, outermost first.
+	scopes []reflect.Type
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// valueSchema returns the schema of the values of type t with the rules
+// of rs, if any, and whether the rules require the value. It returns a
+// nil schema for the types encoding/json cannot encode.
+func (b *schemaBuilder) valueSchema(t reflect.Type, rs *ruleSet) (*Schema, bool, error) {
+	// nil pointers, slices and maps are encoded as null
+	null := t.Kind() == reflect.Ptr
+	t = indirectType(t)
+	null = null || t.Kind() == reflect.Slice || t.Kind() == reflect.Map
+	s, err := b.typeSchema(t)
+	if s == nil || err != nil {
+		return nil, false, err
+	}
+	var required bool
+	if rs != nil {
+		// the rules following omitempty only apply to values that
+		// are not empty
+		target, empty := s, (*Schema)(nil)
+		for i := range rs.tags {
+			tg := &rs.tags[i]
+			if tg.Name == omitEmptyTag {
+				// null values are empty, and allowed unless required
+				if empty = emptySchema(t); (empty != nil || null) && target == s {
+					target = &Schema{}
+				}
+				continue
+			}
+			if tg.Expr != nil {
+				sub, err := b.exprSchema(tg.Expr, t, rs.src)
+				if err != nil {
+					return nil, false, err
+				}
+				if sub != nil {
+					target.AllOf = append(target.AllOf, sub)
+				}
+				continue
+			}
+			sf := b.cfg.schemaFuncs[tg.Name]
+			if sf == nil {
+				continue
+			}
+			r := &SchemaRule{Schema: target, Type: t, Param: tg.Param}
+			if err := sf(r); err != nil {
+				return nil, false, schemaTagError(tg, rs.src, err)
+			}
+			required = required || r.Required && target == s
+		}
+		switch {
+		case target == s || reflect.DeepEqual(target, &Schema{}):
+		case empty == nil:
+			s.AllOf = append(s.AllOf, target)
+		default:
+			s.AllOf = append(s.AllOf, &Schema{AnyOf: []*Schema{empty, target}})
+		}
+		if err := b.diveSchema(s, t, rs); err != nil {
+			return nil, false, err
+		}
+	}
+	if null && !required {
+		s = nullable(s)
+	}
+	return s, required, nil
+}
+
+// exprSchema returns the schema of the expression e applied to values of
+// type t, or nil when it cannot be written: when one of its alternatives
+// has no schema, or none of its rules has one. Rules within expressions
+// never make the value required.
+func (b *schemaBuilder) exprSchema(e *tagExpr, t reflect.Type, src string) (*Schema, error) {
+	switch e.op {
+	case tagsyntax.Leaf:
+		sf := b.cfg.schemaFuncs[e.tag.Name]
+		if sf == nil {
+			return nil, nil
+		}
+		s := &Schema{}
+		if err := sf(&SchemaRule{Schema: s, Type: t, Param: e.tag.Param}); err != nil {
+			return nil, schemaTagError(&e.tag, src, err)
+		}
+		return s, nil
+	case tagsyntax.Not:
+		sub, err := b.exprSchema(e.args[0], t, src)
+		if sub == nil || err != nil {
+			return nil, err
+		}
+		return &Schema{Not: sub}, nil
+	}
+	subs := make([]*Schema, 0, len(e.args))
+	for _, a := range e.args {
+		sub, err := b.exprSchema(a, t, src)
+		if err != nil {
+			return nil, err
+		}
+		if sub == nil {
+			if e.op == tagsyntax.Or {
+				// an alternative without constraints
+				return nil, nil
+			}
+			continue
+		}
+		subs = append(subs, sub)
+	}
+	switch {
+	case len(subs) == 0:
+		return nil, nil
+	case e.op == tagsyntax.Or:
+		return &Schema{AnyOf: subs}, nil
+	}
+	return &Schema{AllOf: subs}, nil
+}
+
+// schemaTagError returns the error of a SchemaFunc failing for tg.
+func schemaTagError(tg *tag, src string, err error) *TagError {
+	offset := tg.Offset
+	if errors.Is(err, ErrBadParameter) {
+		offset = tg.ParamOffset
+	}
+	return &TagError{Tag: src, Rule: tg.Name, Offset: offset, Err: err}
+}
+
+// diveSchema replaces the schemas of the elements of s, of type t, by
+// those with the rules rs dives into.
+func (b *schemaBuilder) diveSchema(s *Schema, t reflect.Type, rs *ruleSet) error {
+	if rs.elems == nil && rs.keys == nil {
+		return nil
+	}
+	var err error
+	switch {
+	case s.Items != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array):
+		if rs.elems != nil {
+			s.Items, _, err = b.valueSchema(t.Elem(), rs.elems)
+		}
+	case s.AdditionalProperties != nil && t.Kind() == reflect.Map:
+		if rs.keys != nil && t.Key().Kind() == reflect.String {
+			// JSON keys are strings, the rules of other keys cannot apply
+			s.PropertyNames, _, err = b.valueSchema(t.Key(), rs.keys)
+		}
+		if err == nil && rs.elems != nil {
+			s.AdditionalProperties, _, err = b.valueSchema(t.Elem(), rs.elems)
+		}
+	default:
+		return &TagError{Tag: rs.src, Rule: diveTag, Offset: rs.dive, Err: ErrUnsupported}
+	}
+	return err
+}
+
+// nullable returns s allowing null as well.
+func nullable(s *Schema) *Schema {
+	switch {
+	case s.Ref != "":
+		return &Schema{AnyOf: []*Schema{s, {Type: SchemaType{"null"}}}}
+	case len(s.Type) == 0:
+		// anything goes already
+		return s
+	}
+	s.Type = append(s.Type, "null")
+	return s
+}
+
+// typeSchema returns the schema of the values of type t, which is not a
+// pointer, without rules.
+func (b *schemaBuilder) typeSchema(t reflect.Type) (*Schema, error) {
+	pt := reflect.PtrTo(t)
+	switch {
+	case t == timeType:
+		return &Schema{Type: SchemaType{"string"}, Format: "date-time"}, nil
+	case t.Implements(jsonMarshalerType) || pt.Implements(jsonMarshalerType):
+		// the encoding is up to the type
+		return &Schema{}, nil
+	case t.Implements(textMarshalerType) || pt.Implements(textMarshalerType):
+		return &Schema{Type: SchemaType{"string"}}, nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: SchemaType{"string"}}, nil
+	case reflect.Bool:
+		return &Schema{Type: SchemaType{"boolean"}}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: SchemaType{"integer"}}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: SchemaType{"number"}}, nil
+	case reflect.Interface:
+		return &Schema{}, nil
+	case reflect.Slice, reflect.Array:
+		if base64Slice(t) {
+			return &Schema{Type: SchemaType{"string"}, ContentEncoding: "base64"}, nil
+		}
+		items, _, err := b.valueSchema(t.Elem(), nil)
+		if items == nil || err != nil {
+			return nil, err
+		}
+		s := &Schema{Type: SchemaType{"array"}, Items: items}
+		if t.Kind() == reflect.Array {
+			n := int64(t.Len())
+			s.MinItems, s.MaxItems = &n, &n
+		}
+		return s, nil
+	case reflect.Map:
+		switch t.Key().Kind() {
+		case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		default:
+			if !t.Key().Implements(textMarshalerType) {
+				return nil, nil
+			}
+		}
+		values, _, err := b.valueSchema(t.Elem(), nil)
+		if values == nil || err != nil {
+			return nil, err
+		}
+		return &Schema{Type: SchemaType{"object"}, AdditionalProperties: values}, nil
+	case reflect.Struct:
+		scope := b.cfg.ruleScope(b.scopes, t)
+		if t.Name() == "" {
+			return b.structSchema(t, scope)
+		}
+		k := planKey{t, scope}
+		name, found := b.names[k]
+		if !found {
+			name = b.defName(t)
+			b.names[k] = name
+			// set before walking the fields, which may refer to t
+			b.defs[name] = nil
+			s, err := b.structSchema(t, scope)
+			if err != nil {
+				return nil, err
+			}
+			b.defs[name] = s
+		}
+		return &Schema{Ref: "#/$defs/" + name}, nil
+	}
+	// channels, functions and complex numbers
+	return nil, nil
+}
+
+// defNameChars matches the characters left out of the names in $defs,
+// so they can be used in references without escaping.
+var defNameChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// defName returns the name in $defs of the named struct type t: its
+// name, qualified by its package if another type has it.
+func (b *schemaBuilder) defName(t reflect.Type) string {
+	name := defNameChars.ReplaceAllString(t.Name(), "_")
+	if _, taken := b.defs[name]; !taken {
+		return name
+	}
+	name = defNameChars.ReplaceAllString(t.PkgPath()+"."+t.Name(), "_")
+	for i := 2; ; i++ {
+		if _, taken := b.defs[name]; !taken {
+			return name
+		}
+		name = defNameChars.ReplaceAllString(t.PkgPath()+"."+t.Name(), "_") + strconv.Itoa(i)
+	}
+}
+
+// structSchema returns the schema of struct type st within scope.
+func (b *schemaBuilder) structSchema(st, scope reflect.Type) (*Schema, error) {
+	s := &Schema{Type: SchemaType{"object"}, Properties: map[string]*Schema{}}
+	if err := b.addFields(s, st, scope); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// addFields adds the fields of struct type st, within scope, to the
+// properties of s, inlining embedded structs as encoding/json does: their
+// fields come after those of st, and do not replace them.
+func (b *schemaBuilder) addFields(s *Schema, st, scope reflect.Type) error {
+	scopes := b.scopes
+	b.scopes = b.cfg.enterScope(scopes, st)
+	defer func() { b.scopes = scopes }()
+	var embedded []reflect.Type
+	for i := 0; i < st.NumField(); i++ {
+		f := st.Field(i)
+		jsonTag := f.Tag.Get("json")
+		if jsonTag == "-" {
+			continue
+		}
+		name := parseName(jsonTag)
+		if ft := indirectType(f.Type); f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			embedded = append(embedded, ft)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if _, found := s.Properties[name]; found {
+			continue
+		}
+		var rs *ruleSet
+		if tag, merged := b.cfg.fieldTags(st, scope, f); tag != "" && tag != "-" {
+			var err error
+			if rs, err = b.cfg.parseFieldTags(tag, merged); err != nil {
+				if te, ok := err.(*TagError); ok {
+					te.Type, te.Field = st, f.Name
+				}
+				return err
+			}
+		}
+		fs, required, err := b.valueSchema(f.Type, rs)
+		if err != nil {
+			if te, ok := err.(*TagError); ok && te.Type == nil {
+				te.Type, te.Field = st, f.Name
+			}
+			return err
+		}
+		if fs == nil {
+			continue
+		}
+		s.Properties[name] = fs
+		if required {
+			s.Required = append(s.Required, name)
+		}
+	}
+	for _, et := range embedded {
+		if err := b.addFields(s, et, b.cfg.ruleScope(b.scopes, et)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// schemaNonzero writes nonzero: the value is required, and strings and
+// collections may not be empty.
+func schemaNonzero(r *SchemaRule) error {
+	r.Required = true
+	if minLen, _ := lengthBounds(r); minLen != nil {
+		raise(minLen, 1)
+	} else if r.Type.Kind() == reflect.Slice {
+		// byte slices encoded as base64 strings
+		raise(&r.Schema.MinLength, 1)
+	}
+	return nil
+}
+
+// lengthBounds returns the keywords bounding the length of the values
+// of r, or nil if their length is not bounded by keywords: for numbers,
+// and for byte slices encoded as base64 strings.
+func lengthBounds(r *SchemaRule) (minLen, maxLen **int64) {
+	s := r.Schema
+	switch r.Type.Kind() {
+	case reflect.String:
+		return &s.MinLength, &s.MaxLength
+	case reflect.Slice, reflect.Array:
+		if !base64Slice(r.Type) {
+			return &s.MinItems, &s.MaxItems
+		}
+	case reflect.Map:
+		return &s.MinProperties, &s.MaxProperties
+	}
+	return nil, nil
+}
+
+// schemaNonnil writes nonnil: the value is required.
+func schemaNonnil(r *SchemaRule) error {
+	r.Required = true
+	return nil
+}
+
+// schemaRange returns the SchemaFunc of min, max and len, setting
+// the lower and/or upper bounds of values.
+func schemaRange(lower, upper bool) SchemaFunc {
+	return func(r *SchemaRule) error {
+		s := r.Schema
+		if minLen, maxLen := lengthBounds(r); minLen != nil {
+			p, err := asInt(r.Param)
+			if err != nil {
+				return ErrBadParameter
+			}
+			if lower {
+				raise(minLen, p)
+			}
+			if upper {
+				lessen(maxLen, p)
+			}
+			return nil
+		}
+
+		var p float64
+		var n json.Number
+		switch r.Type.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i, err := asInt(r.Param)
+			if err != nil {
+				return ErrBadParameter
+			}
+			p, n = float64(i), json.Number(strconv.FormatInt(i, 10))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			u, err := asUint(r.Param)
+			if err != nil {
+				return ErrBadParameter
+			}
+			p, n = float64(u), json.Number(strconv.FormatUint(u, 10))
+		case reflect.Float32, reflect.Float64:
+			f, err := asFloat(r.Param)
+			if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+				return ErrBadParameter
+			}
+			p, n = f, json.Number(strconv.FormatFloat(f, 'g', -1, 64))
+		case reflect.Slice:
+			// byte slices encoded as base64 strings, of 4 characters
+			// for each 3 bytes started
+			i, err := asInt(r.Param)
+			if err != nil {
+				return ErrBadParameter
+			}
+			if i > 0 {
+				i = 4 * ((i + 2) / 3)
+			}
+			if lower {
+				raise(&s.MinLength, i)
+			}
+			if upper {
+				lessen(&s.MaxLength, i)
+			}
+			return nil
+		default:
+			return ErrUnsupported
+		}
+		if lower {
+			if cur, err := s.Minimum.Float64(); s.Minimum == "" || err == nil && p > cur {
+				s.Minimum = n
+			}
+		}
+		if upper {
+			if cur, err := s.Maximum.Float64(); s.Maximum == "" || err == nil && p < cur {
+				s.Maximum = n
+			}
+		}
+		return nil
+	}
+}
+
+// schemaRegexp writes regexp as a pattern.
+func schemaRegexp(r *SchemaRule) error {
+	if r.Type.Kind() != reflect.String {
+		return ErrUnsupported
+	}
+	if _, err := compileRegexp(r.Param); err != nil {
+		return ErrBadParameter
+	}
+	s := r.Schema
+	if s.Pattern == "" {
+		s.Pattern = r.Param
+	} else {
+		// a schema has a single pattern
+		s.AllOf = append(s.AllOf, &Schema{Pattern: r.Param})
+	}
+	return nil
+}
+
+// base64Slice reports whether values of type t are written as base64
+// strings, as encoding/json writes byte slices.
+func base64Slice(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 &&
+		!reflect.PtrTo(t.Elem()).Implements(textMarshalerType)
+}
+
+// emptySchema returns the schema of the values of type t omitempty
+// finds empty, or nil for the types whose values are never empty and for
+// structs, whose empty values the schema does not describe.
+func emptySchema(t reflect.Type) *Schema {
+	zero := int64(0)
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{MaxLength: &zero}
+	case reflect.Slice, reflect.Array:
+		if base64Slice(t) {
+			return &Schema{MaxLength: &zero}
+		}
+		return &Schema{MaxItems: &zero}
+	case reflect.Map:
+		return &Schema{MaxProperties: &zero}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return &Schema{Enum: []interface{}{0}}
+	case reflect.Bool:
+		return &Schema{Enum: []interface{}{false}}
+	}
+	return nil
+}
+
+// raise sets the lower bound *b to p unless it is higher already.
+func raise(b **int64, p int64) {
+	if *b == nil || **b < p {
+		*b = &p
+	}
+}
+
+// lessen sets the upper bound *b to p unless it is lower already.
+func lessen(b **int64, p int64) {
+	if *b == nil || **b > p {
+		*b = &p
+	}
+}
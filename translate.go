@@ -0,0 +1,373 @@
+// Package validator implements value validations
+//
+// Copyright 2014 Roberto Teixeira <robteix@robteix.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Translation holds what a Translator is given to translate the error of
+// a rule.
+type Translation struct {
+	// Locale is the locale of the message, such as "en" or "nl-BE".
+	Locale string
+	// Rule is the name of the rule that failed, or the text of an
+	// expression. It is empty for errors not reported by a rule,
+	// such as ErrCycle.
+	Rule string
+	// Err is the error returned by the rule, usually one of the
+	// package sentinels such as ErrMin.
+	Err error
+	// Label is the label of the field, or its name when it has none.
+	// It is empty for values checked with Valid.
+	Label string
+	// Param is the parameter of the rule (e.g. "18").
+	Param string
+	// Value is the value that failed the rule.
+	Value interface{}
+}
+
+// Translator translates the errors of rules into messages.
+type Translator interface {
+	// Translate returns the message for t, or false if it has none.
+	Translate(t Translation) (string, bool)
+}
+
+// TranslatorFunc is a function used as a Translator.
+type TranslatorFunc func(t Translation) (string, bool)
+
+// Translate returns f(t).
+func (f TranslatorFunc) Translate(t Translation) (string, bool) {
+	return f(t)
+}
+
+// Catalog is a Translator holding message templates indexed by locale and
+// then by key. A locale missing from the catalog is looked up again without
+// its region, so "nl-BE" uses the templates of "nl".
+//
+// The key of an error is the name of its rule, or the key of the sentinel
+// it wraps: the name of the builtin rule returning it, or one of
+// unsupported, badparameter, unknowntag, invalid, cannotvalidate, cycle,
+// maxdepth, type and not. Errors wrapping ErrUnsupported, ErrBadParameter
+// or ErrUnknownTag always use the key of their sentinel. For a string,
+// the key suffixed with ".chars" is tried first, and ".items" for a slice,
+// array or map, each of them first followed by the plural form of the
+// parameter, ".one" or ".other".
+//
+// The placeholders {label}, {rule}, {param} and {value} of templates are
+// replaced by those of the translation. The template of the key "value"
+// stands for an empty label, and the one of "or" joins the messages of
+// alternatives.
+type Catalog map[string]map[string]string
+
+// Translate returns the message of the template found for t.
+func (c Catalog) Translate(t Translation) (string, bool) {
+	msgs := c.messages(t.Locale)
+	if msgs == nil {
+		return "", false
+	}
+	switch err := t.Err.(type) {
+	case AlternativesError:
+		return c.join(t, err, msgs["or"])
+	case ErrorArray:
+		return c.join(t, err, ", ")
+	}
+	for _, key := range translationKeys(t) {
+		if tmpl, found := msgs[key]; found {
+			label := t.Label
+			if label == "" {
+				label = msgs["value"]
+			}
+			r := strings.NewReplacer("{label}", label, "{rule}", t.Rule, "{param}", t.Param, "{value}", fmt.Sprint(t.Value))
+			return r.Replace(tmpl), true
+		}
+	}
+	return "", false
+}
+
+// messages returns the templates of locale, or of its language.
+func (c Catalog) messages(locale string) map[string]string {
+	if msgs, found := c[locale]; found {
+		return msgs
+	}
+	if i := strings.IndexAny(locale, "-_"); i > 0 {
+		return c[locale[:i]]
+	}
+	return nil
+}
+
+// join translates the errors of the rules of an expression, with the
+// label of t, and joins them with sep.
+func (c Catalog) join(t Translation, errs []error, sep string) (string, bool) {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		et := t
+		et.Err = err
+		if fe, ok := err.(*FieldError); ok {
+			et.Rule, et.Err, et.Param, et.Value = fe.Rule, fe.Err, fe.Param, fe.Value
+		}
+		msg, ok := c.Translate(et)
+		if !ok {
+			return "", false
+		}
+		msgs[i] = msg
+	}
+	return strings.Join(msgs, sep), true
+}
+
+// sentinelKeys are the catalog keys of the sentinel errors, in the order
+// they are tested.
+var sentinelKeys = []struct {
+	err error
+	key string
+}{
+	{ErrUnsupported, "unsupported"},
+	{ErrBadParameter, "badparameter"},
+	{ErrUnknownTag, "unknowntag"},
+	{ErrZeroValue, "nonzero"},
+	{ErrMin, "min"},
+	{ErrMax, "max"},
+	{ErrLen, "len"},
+	{ErrRegexp, "regexp"},
+	{ErrEqField, "eqfield"},
+	{ErrNeField, "nefield"},
+	{ErrGtField, "gtfield"},
+	{ErrGteField, "gtefield"},
+	{ErrLtField, "ltfield"},
+	{ErrLteField, "ltefield"},
+	{ErrRequiredIf, "required_if"},
+	{ErrRequiredUnless, "required_unless"},
+	{ErrRequiredWith, "required_with"},
+	{ErrRequiredWithout, "required_without"},
+	{ErrInvalid, "invalid"},
+	{ErrCannotValidate, "cannotvalidate"},
+	{ErrCycle, "cycle"},
+	{ErrMaxDepth, "maxdepth"},
+	{ErrType, "type"},
+	{ErrNot, "not"},
+}
+
+// paramErrors are the errors of the builtin rules whose messages need
+// the parameter of the rule.
+var paramErrors = []error{
+	ErrMin, ErrMax, ErrLen,
+	ErrEqField, ErrNeField, ErrGtField, ErrGteField, ErrLtField, ErrLteField,
+}
+
+// translationKeys returns the catalog keys of t, in the order they
+// are looked up.
+func translationKeys(t Translation) []string {
+	var sentinel string
+	for _, s := range sentinelKeys {
+		if errors.Is(t.Err, s.err) {
+			sentinel = s.key
+			break
+		}
+	}
+	var bases []string
+	switch sentinel {
+	case "unsupported", "badparameter", "unknowntag":
+		bases = []string{sentinel}
+	default:
+		if t.Rule != "" {
+			bases = append(bases, t.Rule)
+		}
+		if sentinel != "" && sentinel != t.Rule {
+			bases = append(bases, sentinel)
+		}
+	}
+	var suffix string
+	switch indirect(reflect.ValueOf(t.Value)).Kind() {
+	case reflect.String:
+		suffix = ".chars"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		suffix = ".items"
+	}
+	if suffix == "" {
+		return bases
+	}
+	form := pluralForm(t.Locale, t.Param)
+	keys := make([]string, 0, 3*len(bases))
+	for _, b := range bases {
+		keys = append(keys, b+suffix+"."+form, b+suffix, b)
+	}
+	return keys
+}
+
+// pluralForm returns "one" when the count n calls for the singular in
+// the language of locale, and "other" otherwise.
+func pluralForm(locale, n string) string {
+	count, err := strconv.ParseInt(n, 10, 64)
+	if err != nil {
+		return "other"
+	}
+	if lang := strings.ToLower(locale); lang == "fr" || strings.HasPrefix(lang, "fr-") || strings.HasPrefix(lang, "fr_") {
+		// French uses the singular for zero too
+		if count == 0 || count == 1 {
+			return "one"
+		}
+		return "other"
+	}
+	if count == 1 {
+		return "one"
+	}
+	return "other"
+}
+
+// DefaultCatalog returns a copy of the catalogs used to translate the
+// errors of the builtin rules, in English (en), Dutch (nl), German (de)
+// and French (fr). It can be extended and set with SetTranslator.
+func DefaultCatalog() Catalog {
+	c := make(Catalog, len(defaultCatalog))
+	for locale, msgs := range defaultCatalog {
+		c[locale] = make(map[string]string, len(msgs))
+		for k, tmpl := range msgs {
+			c[locale][k] = tmpl
+		}
+	}
+	return c
+}
+
+// SetTranslator calls the SetTranslator method on the default validator.
+func SetTranslator(t Translator) {
+	defaultValidator.SetTranslator(t)
+}
+
+// SetTranslator sets the Translator used by Translate. The messages it
+// has none for are taken from the bundled catalogs. A nil Translator
+// leaves only the bundled catalogs.
+func (mv *Validator) SetTranslator(t Translator) {
+	mv.update(func(cfg *config) {
+		cfg.translator = t
+	})
+}
+
+// WithTranslator creates a new Validator with the given Translator.
+func WithTranslator(t Translator) *Validator {
+	return defaultValidator.WithTranslator(t)
+}
+
+// WithTranslator creates a new Validator with the given Translator.
+func (mv *Validator) WithTranslator(t Translator) *Validator {
+	v := mv.copy()
+	v.SetTranslator(t)
+	return v
+}
+
+// localeKey is the key of the locale in contexts.
+type localeKey struct{}
+
+// ContextWithLocale returns a copy of ctx carrying locale, so the errors
+// returned by ValidateContext and ValidContext are translated to it.
+func ContextWithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+// LocaleFromContext returns the locale carried by ctx, or "" if none.
+func LocaleFromContext(ctx context.Context) string {
+	locale, _ := ctx.Value(localeKey{}).(string)
+	return locale
+}
+
+// Translate calls the Translate method on the default validator.
+func Translate(err error, locale string) error {
+	return defaultValidator.Translate(err, locale)
+}
+
+// Translate returns a copy of err, as returned by Validate or Valid, where
+// the FieldErrors have the message of their translation to locale. Other
+// errors that have a translation, such as ErrCycle, are replaced by a
+// FieldError wrapping them. Errors without translation and FieldErrors
+// that already have a message are left unchanged, as are the errors of
+// rules whose message needs their parameter, such as ErrMin, unless they
+// are reported as FieldErrors with SetFieldErrors.
+func (mv *Validator) Translate(err error, locale string) error {
+	return mv.config().translate(err, locale, "")
+}
+
+// translateContext translates err to the locale carried by ctx, if any.
+func (cfg *config) translateContext(ctx context.Context, err error) error {
+	if locale := LocaleFromContext(ctx); locale != "" {
+		return cfg.translate(err, locale, "")
+	}
+	return err
+}
+
+// translate translates err, found at path, to locale.
+func (cfg *config) translate(err error, locale, path string) error {
+	switch err := err.(type) {
+	case ErrorMap:
+		m := make(ErrorMap, len(err))
+		for p, errs := range err {
+			m[p] = cfg.translate(errs, locale, p).(ErrorArray)
+		}
+		return m
+	case ErrorArray:
+		errs := make(ErrorArray, len(err))
+		for i, e := range err {
+			errs[i] = cfg.translate(e, locale, path)
+		}
+		return errs
+	case *FieldError:
+		if err.Message != "" {
+			return err
+		}
+		label := err.Label
+		for _, name := range []string{err.Name, err.Field, err.Path} {
+			if label == "" {
+				label = name
+			}
+		}
+		t := Translation{Locale: locale, Rule: err.Rule, Err: err.Err, Label: label, Param: err.Param, Value: err.Value}
+		msg, ok := cfg.translateMessage(t)
+		if !ok {
+			return err
+		}
+		fe := *err
+		fe.Message = msg
+		return &fe
+	case nil:
+		return nil
+	}
+	for _, pe := range paramErrors {
+		if errors.Is(err, pe) {
+			// the parameter of the message is unknown
+			return err
+		}
+	}
+	msg, ok := cfg.translateMessage(Translation{Locale: locale, Err: err, Label: path})
+	if !ok {
+		return err
+	}
+	return &FieldError{Path: path, Err: err, Message: msg}
+}
+
+// translateMessage returns the message of the translator for t, or else
+// the one of the bundled catalogs.
+func (cfg *config) translateMessage(t Translation) (string, bool) {
+	if cfg.translator != nil {
+		if msg, ok := cfg.translator.Translate(t); ok {
+			return msg, true
+		}
+	}
+	return defaultCatalog.Translate(t)
+}
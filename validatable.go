@@ -0,0 +1,159 @@
+// Package validator implements value validations
+//
+// Copyright 2014 Roberto Teixeira <robteix@robteix.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validator
+
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+// Validatable is implemented by types holding validation logic that
+// cannot be expressed with tags. Once SetCallValidate is set, Validate
+// calls the Validate method of every value it walks through that
+// implements it, in addition to checking its tags.
+//
+// Calling the methods is opt-in: existing Validate methods often check
+// their receiver with the package Validate function, which would call
+// them again forever, and validators not calling them do not walk the
+// slices and maps of values that can only matter for their methods.
+//
+// Errors returned are added to those found for the value; the keys of a
+// returned ErrorMap are taken relative to the value's own path. The Validate
+// method must not validate its receiver with a Validator calling it, or it
+// would be called again forever.
+type Validatable interface {
+	Validate() error
+}
+
+// ContextValidatable is like Validatable but its method is also given the
+// context and the Validator of the ongoing validation, so it can check other
+// values with the same configuration (e.g. v.Valid(x, "min=3")). As for
+// Validatable, the tags of the receiver are checked by the ongoing validation
+// anyway. The Validator passed does not call the method again when the value
+// it validates is of the type of the receiver. Types implementing both
+// interfaces only have ValidateWithContext called.
+type ContextValidatable interface {
+	ValidateWithContext(ctx context.Context, v *Validator) error
+}
+
+var (
+	validatableType        = reflect.TypeOf((*Validatable)(nil)).Elem()
+	contextValidatableType = reflect.TypeOf((*ContextValidatable)(nil)).Elem()
+)
+
+// SetCallValidate calls the SetCallValidate method on the default validator.
+func SetCallValidate(callValidate bool) {
+	defaultValidator.SetCallValidate(callValidate)
+}
+
+// SetCallValidate sets whether the methods of Validatable and
+// ContextValidatable values are called while validating them. They are
+// not by default, so Validate methods validating their receiver with the
+// package Validate function keep working.
+func (mv *Validator) SetCallValidate(callValidate bool) {
+	mv.update(func(cfg *config) {
+		cfg.callValidate = callValidate
+	})
+}
+
+// WithCallValidate creates a new Validator with callValidate set to new value.
+func WithCallValidate(callValidate bool) *Validator {
+	return defaultValidator.WithCallValidate(callValidate)
+}
+
+// WithCallValidate creates a new Validator with callValidate set to new value.
+func (mv *Validator) WithCallValidate(callValidate bool) *Validator {
+	v := mv.copy()
+	v.SetCallValidate(callValidate)
+	return v
+}
+
+// selfValidatingTypes caches the result of selfValidating by type.
+var selfValidatingTypes sync.Map // map[reflect.Type]bool
+
+// selfValidating reports whether values of type t, or pointers to them,
+// implement Validatable or ContextValidatable.
+func selfValidating(t reflect.Type) bool {
+	if ok, found := selfValidatingTypes.Load(t); found {
+		return ok.(bool)
+	}
+	pt := reflect.PtrTo(t)
+	ok := t.Implements(validatableType) || t.Implements(contextValidatableType) ||
+		pt.Implements(validatableType) || pt.Implements(contextValidatableType)
+	selfValidatingTypes.Store(t, ok)
+	return ok
+}
+
+// validateSelf calls the Validate or ValidateWithContext method of f, if
+// any, and adds the error returned to the errors found under path.
+func (cfg *config) validateSelf(f reflect.Value, vs *validationState, path string) {
+	if t := vs.skipSelf; t != nil {
+		vs.skipSelf = nil
+		if t == f.Type() {
+			// f is the receiver of the method that started this validation
+			return
+		}
+	}
+	if !cfg.callValidate || !selfValidating(f.Type()) || !f.CanInterface() {
+		return
+	}
+	target := f
+	if f.CanAddr() {
+		target = f.Addr()
+	} else if f.Kind() != reflect.Ptr {
+		// methods with a pointer receiver are called on a copy
+		target = reflect.New(f.Type())
+		target.Elem().Set(f)
+	}
+
+	var err error
+	switch x := target.Interface().(type) {
+	case ContextValidatable:
+		// the snapshot shares the maps and plans of cfg, only skipSelf differs
+		nv := *cfg
+		nv.skipSelf = f.Type()
+		err = x.ValidateWithContext(vs.ctx, newValidator(&nv))
+	case Validatable:
+		err = x.Validate()
+	}
+	mergeErrors(vs.m, path, err)
+}
+
+// mergeErrors adds err to m under path. The keys of an ErrorMap are
+// prefixed with path, and so are the paths of the FieldErrors it holds.
+func mergeErrors(m ErrorMap, path string, err error) {
+	switch e := err.(type) {
+	case nil:
+	case ErrorMap:
+		for k, errs := range e {
+			key := joinPath(path, k)
+			for _, err := range errs {
+				if fe, ok := err.(*FieldError); ok {
+					c := *fe
+					c.Path = joinPath(path, fe.Path)
+					err = &c
+				}
+				m[key] = append(m[key], err)
+			}
+		}
+	case ErrorArray:
+		m[path] = append(m[path], e...)
+	default:
+		m[path] = append(m[path], err)
+	}
+}
@@ -0,0 +1,36 @@
+// Package validator implements value validations
+//
+// Copyright 2014 Roberto Teixeira <robteix@robteix.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command validatorvet checks the validation tags of struct fields.
+//
+// Usage:
+//
+//	validatorvet [-tagnames validate,other] [-rules custom1,custom2] packages...
+//
+// It can also be run by go vet:
+//
+//	go vet -vettool=$(which validatorvet) ./...
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/singlechecker"
+
+	"gopkg.in/validator.v2/validatetag"
+)
+
+func main() {
+	singlechecker.Main(validatetag.Analyzer)
+}
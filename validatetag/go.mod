@@ -0,0 +1,19 @@
+module gopkg.in/validator.v2/validatetag
+
+go 1.23.0
+
+require (
+	golang.org/x/tools v0.36.0
+	gopkg.in/validator.v2 v2.0.2-0.20261017022755-144dd8bde8f7
+)
+
+require (
+	golang.org/x/mod v0.27.0 // indirect
+	golang.org/x/sync v0.16.0 // indirect
+)
+
+// The analyzer shares the tag grammar of the validator, in the
+// internal/tagsyntax package no release has yet, so it requires the
+// commit of the validator it was last changed with. Within the repository
+// it is built against the validator next to it.
+replace gopkg.in/validator.v2 => ../
@@ -0,0 +1,47 @@
+package a
+
+import "time"
+
+type Address struct {
+	Street string `validate:"nonzero"`
+	Zip    string `validate:"len=5,regexp=^[0-9]+$"`
+}
+
+type User struct {
+	Name     string            `validate:"nonzero,minn=2"` // want `validate tag "nonzero,minn=2": unknown rule "minn"`
+	Age      int               `validate:"min=abc"`        // want `validate tag "min=abc": min: bad parameter "abc" for int`
+	Ratio    float64           `validate:"max=1.5"`
+	Count    uint              `validate:"max=-1"`          // want `max: bad parameter "-1" for uint`
+	Code     int               `validate:"regexp=^[0-9]+$"` // want `regexp: unsupported on int`
+	Email    string            `validate:"regexp=([a-z]"`   // want `regexp: error parsing regexp`
+	Pattern  string            `validate:"regexp=^a{1,3}$"` // want `unescaped comma in the parameter of "regexp=\^a\{1"`
+	Escaped  string            `validate:"regexp=^a{1\\,3}$"`
+	Tags     []string          `validate:"max=5,dive,min=3"`
+	Labels   map[string]string `validate:"dive,keys,min=2,endkeys,regexp=^x"`
+	Scores   map[string]int    `validate:"dive,keys,max=x,endkeys,nonzero"` // want `max: bad parameter "x" for string`
+	Single   int               `validate:"dive,min=1"`                      // want `dive on int, which is not a slice, array or map`
+	Keys     []int             `validate:"dive,keys,min=1,endkeys"`         // want `keys on \[\]int, which is not a map`
+	Open     []int             `validate:"dive,keys,min=1"`                 // want `unexpected endkeys at offset 15`
+	Ptr      *string           `validate:"nonzero,regexp=^a"`
+	Start    time.Time         `validate:"nonzero,ltfield=End"`
+	End      time.Time         `validate:"gtfield"`          // want `gtfield: missing parameter`
+	Kind     string            `validate:"required_if=Name"` // want `required_if: bad parameter "Name", want field and value pairs`
+	Callback func()            `validate:"nonzero"`          // want `nonzero: unsupported on func\(\)`
+	Any      interface{}       `validate:"regexp=^a,min=x"`
+	Custom   string            `validate:"custom" json:"custom"`        // want `unknown rule "custom"`
+	Other    string            `other:"nonzero,foo" validate:"nonzero"` // want `other tag "nonzero,foo": unknown rule "foo"`
+	Skipped  string            `validate:"-"`
+	Address  Address
+	Blank    string `validate:"nonzero, ,min=1"` // want `missing rule name at offset 9`
+	Either   string `validate:"(len=0|regexp=^[0-9]{5}$),max=5"`
+	Role     string `validate:"nonzero,!(regexp=^(admin|root)$|len=1)"`
+	Typo     string `validate:"(len=0|lenn=5)"` // want `unknown rule "lenn"`
+	Unclosed string `validate:"(len=0|len=5"`   // want `unclosed \( at offset 0`
+	Negated  int    `validate:"!regexp=^a"`     // want `regexp: unsupported on int`
+	Website  string `validate:"omitempty,regexp=^https://"`
+	Omit     string `validate:"omitempty=1"`       // want `omitempty: unexpected parameter "1"`
+	OmitExpr string `validate:"(omitempty|len=1)"` // want `unexpected omitempty at offset 1`
+	Message  string `validate:"nonzero,msg=required\, really,code=E1"`
+	MsgExpr  string `validate:"(msg=x|len=1)"` // want `unexpected msg at offset 1`
+	Bare     string `validate:"min=3|len=2"`   // want `min: bad parameter "3\|len=2" for string, alternatives need parentheses, as in \(min=3\|len=2\)`
+}
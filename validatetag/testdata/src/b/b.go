@@ -0,0 +1,6 @@
+package b
+
+type T struct {
+	A string `other:"nonzero,foo=1" validate:"unknown"`
+	B string `other:"bar"` // want `other tag "bar": unknown rule "bar"`
+}
@@ -0,0 +1,393 @@
+// Package validator implements value validations
+//
+// Copyright 2014 Roberto Teixeira <robteix@robteix.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package validatetag defines an Analyzer that checks the validation
+// tags of struct fields at compile time.
+//
+// It reports unknown rules, parameters of min, max and len that cannot
+// be parsed for the type of the field, regular expressions that do not
+// compile, commas left unescaped within parameters, alternatives written
+// without parentheses and rules applied to fields of a kind they do not
+// support. Tags are read with the same grammar as the validator package
+// uses at run time.
+package validatetag
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+
+	"gopkg.in/validator.v2/internal/tagsyntax"
+)
+
+// Doc is the documentation of the analyzer.
+const Doc = `check that validation struct tags are well formed
+
+The validatetag analyzer reports unknown rules, unparsable parameters of
+min, max and len, invalid regular expressions, unescaped commas within
+parameters, alternatives written without parentheses and rules applied to
+fields of a kind they do not support.`
+
+// Analyzer checks the tags read by the default validator. Its -tagnames
+// and -rules flags set the tag names and the additional rule names.
+var Analyzer = NewAnalyzer(Config{})
+
+// Config configures an analyzer created with NewAnalyzer.
+type Config struct {
+	// TagNames are the names of the struct tags holding rules,
+	// as set with SetTag. It defaults to "validate".
+	TagNames []string
+	// Rules are the names of the rules set with SetValidationFunc,
+	// known in addition to the builtin rules. Their parameters are
+	// not checked.
+	Rules []string
+}
+
+// NewAnalyzer returns an analyzer checking the tags described by cfg.
+// The -tagnames and -rules flags of the analyzer override cfg.
+func NewAnalyzer(cfg Config) *analysis.Analyzer {
+	c := &checker{tagNames: cfg.TagNames, rules: cfg.Rules}
+	if len(c.tagNames) == 0 {
+		c.tagNames = []string{"validate"}
+	}
+	a := &analysis.Analyzer{
+		Name:             "validatetag",
+		Doc:              Doc,
+		Requires:         []*analysis.Analyzer{inspect.Analyzer},
+		RunDespiteErrors: true,
+		Run:              c.run,
+	}
+	a.Flags.Var((*listFlag)(&c.tagNames), "tagnames", "comma separated names of the struct tags holding rules")
+	a.Flags.Var((*listFlag)(&c.rules), "rules", "comma separated names of custom rules")
+	return a
+}
+
+// listFlag is a flag.Value holding a comma separated list.
+type listFlag []string
+
+var _ flag.Value = (*listFlag)(nil)
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(s string) error {
+	*l = nil
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// builtinRules are the rules of a new validator.Validator, with the
+// function checking their use.
+var builtinRules = map[string]func(k reflect.Kind, t types.Type, param string) string{
+	"nonzero": checkKinds(nonzeroKinds),
+	"len":     checkRange,
+	"min":     checkRange,
+	"max":     checkRange,
+	"regexp":  checkRegexp,
+	"nonnil":  nil,
+
+	"omitempty": checkNoParam,
+
+	"eqfield":  checkNonEmpty,
+	"nefield":  checkNonEmpty,
+	"gtfield":  checkNonEmpty,
+	"gtefield": checkNonEmpty,
+	"ltfield":  checkNonEmpty,
+	"ltefield": checkNonEmpty,
+
+	"required_if":      checkPairs,
+	"required_unless":  checkPairs,
+	"required_with":    checkNonEmpty,
+	"required_without": checkNonEmpty,
+}
+
+// checker holds the configuration of an analyzer.
+type checker struct {
+	tagNames []string
+	rules    []string
+}
+
+func (c *checker) run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	inspect.Preorder([]ast.Node{(*ast.Field)(nil)}, func(n ast.Node) {
+		field := n.(*ast.Field)
+		if field.Tag == nil {
+			return
+		}
+		lit, err := strconv.Unquote(field.Tag.Value)
+		if err != nil {
+			return
+		}
+		t := pass.TypesInfo.TypeOf(field.Type)
+		if t == nil {
+			// type information may be incomplete
+			return
+		}
+		for _, name := range c.tagNames {
+			tag, ok := reflect.StructTag(lit).Lookup(name)
+			if !ok || tag == "-" {
+				continue
+			}
+			for _, msg := range c.checkTag(tag, t) {
+				pass.Reportf(field.Tag.Pos(), "%s tag %q: %s", name, tag, msg)
+			}
+		}
+	})
+	return nil, nil
+}
+
+// checkTag returns the problems found in tag, the tag of a field of type t.
+func (c *checker) checkTag(tag string, t types.Type) []string {
+	rs, err := tagsyntax.Parse(tag)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	return c.checkRuleSet(rs, tagsyntax.Split(tag), t)
+}
+
+// checkRuleSet returns the problems found in the rules of rs applied
+// to values of type t. items are the items of the full tag.
+func (c *checker) checkRuleSet(rs *tagsyntax.RuleSet, items []tagsyntax.Item, t types.Type) []string {
+	var msgs []string
+	k, known := kindOf(t)
+	var rules []tagsyntax.Rule
+	for _, r := range rs.Rules {
+		if r.Expr != nil {
+			rules = append(rules, r.Expr.Leaves()...)
+		} else {
+			rules = append(rules, r)
+		}
+	}
+	for _, r := range rules {
+		check, builtin := builtinRules[r.Name]
+		switch {
+		case builtin:
+			if check != nil && known {
+				if msg := check(k, t, r.Param); msg != "" {
+					if r.Name != "regexp" && tagsyntax.BareAlternatives(r.Param) {
+						msg += fmt.Sprintf(", alternatives need parentheses, as in (%s=%s)", r.Name, r.Param)
+					}
+					msgs = append(msgs, fmt.Sprintf("%s: %s", r.Name, msg))
+				}
+			}
+		case c.custom(r.Name):
+		default:
+			if prev := previousItem(items, r.Offset); !ruleName.MatchString(r.Name) && strings.Contains(prev.Text, "=") {
+				msgs = append(msgs, fmt.Sprintf("unescaped comma in the parameter of %q, escape it as \\,", strings.TrimSpace(prev.Text)))
+			} else {
+				msgs = append(msgs, fmt.Sprintf("unknown rule %q", r.Name))
+			}
+		}
+	}
+	if rs.Keys == nil && rs.Elems == nil {
+		return msgs
+	}
+	// element types stay nil when unknown
+	var keyType, elemType types.Type
+	if known {
+		switch u := deref(t).Underlying().(type) {
+		case *types.Slice:
+			elemType = u.Elem()
+		case *types.Array:
+			elemType = u.Elem()
+		case *types.Map:
+			keyType, elemType = u.Key(), u.Elem()
+		default:
+			return append(msgs, fmt.Sprintf("dive on %s, which is not a slice, array or map", t))
+		}
+		if rs.Keys != nil && keyType == nil {
+			msgs = append(msgs, fmt.Sprintf("keys on %s, which is not a map", t))
+		}
+	}
+	if rs.Keys != nil {
+		msgs = append(msgs, c.checkRuleSet(rs.Keys, items, keyType)...)
+	}
+	if rs.Elems != nil {
+		msgs = append(msgs, c.checkRuleSet(rs.Elems, items, elemType)...)
+	}
+	return msgs
+}
+
+// custom reports whether name is one of the rules set in the configuration.
+func (c *checker) custom(name string) bool {
+	for _, r := range c.rules {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleName matches the names rules can have. Other names are
+// usually the end of a parameter cut at an unescaped comma.
+var ruleName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// previousItem returns the item before the one at offset, if any.
+func previousItem(items []tagsyntax.Item, offset int) tagsyntax.Item {
+	var prev tagsyntax.Item
+	for _, item := range items {
+		if item.Offset <= offset && offset < item.Offset+len(item.Text) {
+			return prev
+		}
+		prev = item
+	}
+	return tagsyntax.Item{}
+}
+
+// deref dereferences pointer types, as rules are applied to the
+// values pointers refer to.
+func deref(t types.Type) types.Type {
+	for {
+		p, ok := t.Underlying().(*types.Pointer)
+		if !ok {
+			return t
+		}
+		t = p.Elem()
+	}
+}
+
+// kindOf returns the reflect.Kind of the values rules are applied to for
+// a field of type t. It reports false when the kind is only known at run
+// time, for interfaces and type parameters, or when t is nil. Numbers
+// are reported as Int64, Uint64, Float64 or Complex128 whatever their size.
+func kindOf(t types.Type) (reflect.Kind, bool) {
+	if t == nil {
+		return reflect.Invalid, false
+	}
+	switch u := deref(t).Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsUntyped != 0:
+			return reflect.Invalid, false
+		case u.Kind() == types.UnsafePointer:
+			return reflect.UnsafePointer, true
+		case u.Info()&types.IsString != 0:
+			return reflect.String, true
+		case u.Info()&types.IsBoolean != 0:
+			return reflect.Bool, true
+		case u.Info()&types.IsComplex != 0:
+			return reflect.Complex128, true
+		case u.Info()&types.IsFloat != 0:
+			return reflect.Float64, true
+		case u.Info()&types.IsUnsigned != 0:
+			return reflect.Uint64, true
+		case u.Info()&types.IsInteger != 0:
+			return reflect.Int64, true
+		}
+	case *types.Struct:
+		return reflect.Struct, true
+	case *types.Slice:
+		return reflect.Slice, true
+	case *types.Array:
+		return reflect.Array, true
+	case *types.Map:
+		return reflect.Map, true
+	case *types.Chan:
+		return reflect.Chan, true
+	case *types.Signature:
+		return reflect.Func, true
+	}
+	return reflect.Invalid, false
+}
+
+// nonzeroKinds are the kinds supported by nonzero, once pointers are
+// dereferenced.
+var nonzeroKinds = []reflect.Kind{
+	reflect.String, reflect.Slice, reflect.Map, reflect.Array,
+	reflect.Int64, reflect.Uint64, reflect.Float64, reflect.Bool, reflect.Struct,
+}
+
+// checkKinds returns a function reporting the kinds not in kinds.
+func checkKinds(kinds []reflect.Kind) func(k reflect.Kind, t types.Type, param string) string {
+	return func(k reflect.Kind, t types.Type, param string) string {
+		for _, s := range kinds {
+			if k == s {
+				return ""
+			}
+		}
+		return fmt.Sprintf("unsupported on %s", deref(t))
+	}
+}
+
+// checkRange checks the use of min, max and len, whose parameter is
+// parsed as the validator package does for values of kind k.
+func checkRange(k reflect.Kind, t types.Type, param string) string {
+	var err error
+	switch k {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array, reflect.Int64:
+		_, err = strconv.ParseInt(param, 0, 64)
+	case reflect.Uint64:
+		_, err = strconv.ParseUint(param, 0, 64)
+	case reflect.Float64:
+		_, err = strconv.ParseFloat(param, 64)
+	default:
+		return fmt.Sprintf("unsupported on %s", deref(t))
+	}
+	if err != nil {
+		return fmt.Sprintf("bad parameter %q for %s", param, deref(t))
+	}
+	return ""
+}
+
+// checkRegexp checks the use of regexp.
+func checkRegexp(k reflect.Kind, t types.Type, param string) string {
+	if k != reflect.String {
+		return fmt.Sprintf("unsupported on %s", deref(t))
+	}
+	if _, err := regexp.Compile(param); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// checkNoParam checks that the rule is given no parameter.
+func checkNoParam(k reflect.Kind, t types.Type, param string) string {
+	if param != "" {
+		return fmt.Sprintf("unexpected parameter %q", param)
+	}
+	return ""
+}
+
+// checkNonEmpty checks that the rule is given a parameter.
+func checkNonEmpty(k reflect.Kind, t types.Type, param string) string {
+	if strings.TrimSpace(param) == "" {
+		return "missing parameter"
+	}
+	return ""
+}
+
+// checkPairs checks the "Field value" list of required_if and
+// required_unless.
+func checkPairs(k reflect.Kind, t types.Type, param string) string {
+	if n := len(strings.Fields(param)); n == 0 || n%2 != 0 {
+		return fmt.Sprintf("bad parameter %q, want field and value pairs", param)
+	}
+	return ""
+}
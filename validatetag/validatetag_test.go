@@ -0,0 +1,53 @@
+// Package validator implements value validations
+//
+// Copyright 2014 Roberto Teixeira <robteix@robteix.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validatetag
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+
+	"gopkg.in/validator.v2"
+)
+
+func TestAnalyzer(t *testing.T) {
+	a := NewAnalyzer(Config{})
+	if err := a.Flags.Set("tagnames", "validate,other"); err != nil {
+		t.Fatal(err)
+	}
+	analysistest.Run(t, analysistest.TestData(), a, "a")
+}
+
+func TestCustomRules(t *testing.T) {
+	a := NewAnalyzer(Config{
+		TagNames: []string{"other"},
+		Rules:    []string{"foo"},
+	})
+	analysistest.Run(t, analysistest.TestData(), a, "b")
+}
+
+// TestBuiltinRules makes sure the analyzer knows the rules of the
+// validator package.
+func TestBuiltinRules(t *testing.T) {
+	for name := range builtinRules {
+		err := validator.Valid(nil, name+"=x")
+		if errors.Is(err, validator.ErrUnknownTag) {
+			t.Errorf("rule %s is unknown to the validator package", name)
+		}
+	}
+}
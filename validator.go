@@ -18,11 +18,17 @@ package validator
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+
+	"gopkg.in/validator.v2/internal/tagsyntax"
 )
 
 // TextErr is an error that also implements the TextMarshaller interface for
@@ -73,6 +79,54 @@ var (
 	ErrInvalid = TextErr{errors.New("invalid value")}
 	// ErrCannotValidate is the error returned when a struct is unexported
 	ErrCannotValidate = TextErr{errors.New("cannot validate unexported struct")}
+	// ErrEqField is the error returned when a variable is not equal
+	// to the field given as parameter to eqfield
+	ErrEqField = TextErr{errors.New("not equal to field")}
+	// ErrNeField is the error returned when a variable is equal
+	// to the field given as parameter to nefield
+	ErrNeField = TextErr{errors.New("equal to field")}
+	// ErrGtField is the error returned when a variable is not greater
+	// than the field given as parameter to gtfield
+	ErrGtField = TextErr{errors.New("not greater than field")}
+	// ErrGteField is the error returned when a variable is less than
+	// the field given as parameter to gtefield
+	ErrGteField = TextErr{errors.New("less than field")}
+	// ErrLtField is the error returned when a variable is not less
+	// than the field given as parameter to ltfield
+	ErrLtField = TextErr{errors.New("not less than field")}
+	// ErrLteField is the error returned when a variable is greater than
+	// the field given as parameter to ltefield
+	ErrLteField = TextErr{errors.New("greater than field")}
+	// ErrRequiredIf is the error returned when a variable has zero value
+	// and the condition given to required_if holds
+	ErrRequiredIf = TextErr{errors.New("required by field value")}
+	// ErrRequiredUnless is the error returned when a variable has zero
+	// value and the condition given to required_unless does not hold
+	ErrRequiredUnless = TextErr{errors.New("required unless field value")}
+	// ErrRequiredWith is the error returned when a variable has zero value
+	// while one of the fields given to required_with is nonzero
+	ErrRequiredWith = TextErr{errors.New("required with field")}
+	// ErrRequiredWithout is the error returned when a variable has zero value
+	// while one of the fields given to required_without is zero
+	ErrRequiredWithout = TextErr{errors.New("required without field")}
+	// ErrCycle is the error returned when a value refers back to itself
+	// and the Validator is set to report cycles
+	ErrCycle = TextErr{errors.New("cycle detected")}
+	// ErrMaxDepth is the error returned when structs are nested deeper
+	// than the maximum depth set on the Validator
+	ErrMaxDepth = TextErr{errors.New("maximum depth exceeded")}
+	// ErrConflict is the error reported by Check and Conflicts when
+	// rules of a field can never be satisfied together (e.g. max=0,min=1)
+	ErrConflict = TextErr{errors.New("conflicting rules")}
+	// ErrType is the error returned by ValidateJSON when a value has
+	// none of the types allowed by its JSON Schema
+	ErrType = TextErr{errors.New("unexpected type")}
+	// ErrUnknownField is the error returned when rules are registered
+	// for a field that does not exist or is not exported
+	ErrUnknownField = TextErr{errors.New("unknown field")}
+	// ErrNot is the error returned when the rule of a negation
+	// written with ! holds
+	ErrNot = TextErr{errors.New("negated rule satisfied")}
 )
 
 // ErrorMap is a map which contains all errors from validating a struct.
@@ -108,30 +162,234 @@ func (err ErrorArray) Error() string {
 	return strings.TrimSuffix(errs, ", ")
 }
 
+// Unwrap returns the errors of the array, so errors.Is and errors.As
+// look at each of them.
+func (err ErrorArray) Unwrap() []error {
+	return err
+}
+
+// FieldError is the error reported when a value fails a validation rule,
+// when SetFieldErrors asks for them or the rule has a message or code in
+// its tag. It wraps the error returned by the rule, so errors.Is(err,
+// ErrMin) holds for a FieldError produced by a failing min rule.
+type FieldError struct {
+	// Path is the full path of the field, as used for ErrorMap keys.
+	// It is empty for values checked with Valid.
+	Path string
+	// Field is the name of the Go struct field.
+	Field string
+	// Name is the name of the field in Path, given by the function
+	// set with SetNameFunc, or the Go field name.
+	Name string
+	// JSONName is the name given to the field by its json tag, or the
+	// Go field name if there is none.
+	JSONName string
+	// Label is the name of the field shown to users, given by its
+	// label tag or the function set with SetLabelFunc. It is empty
+	// when the field has none.
+	Label string
+	// Rule is the name of the rule that failed (e.g. "min").
+	Rule string
+	// Param is the parameter of the rule (e.g. "18").
+	Param string
+	// Value is the value that failed the rule.
+	Value interface{}
+	// Err is the error returned by the rule, usually one of the
+	// package sentinels such as ErrMin.
+	Err error
+	// Message and Code are those given to the rule in its tag with
+	// msg and code, if any. The placeholders of Message are replaced.
+	Message string
+	Code    string
+}
+
+// Error implements the error interface. It returns the message given to
+// the rule in its tag, or else the message of the underlying error so
+// printed ErrorMaps are unchanged.
+func (e *FieldError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return e.Err.Error()
+}
+
+// messagePlaceholder matches the placeholders of the messages of tags.
+var messagePlaceholder = regexp.MustCompile(`\{(field|label|path|rule|param|value)\}`)
+
+// expandMessage returns the message template tmpl with the placeholders
+// {field}, {label}, {path}, {rule}, {param} and {value} replaced by those
+// of e. {label} is replaced by the field name when e has no label.
+func (e *FieldError) expandMessage(tmpl string) string {
+	return messagePlaceholder.ReplaceAllStringFunc(tmpl, func(p string) string {
+		switch p {
+		case "{field}":
+			return e.Field
+		case "{label}":
+			if e.Label != "" {
+				return e.Label
+			}
+			return e.Field
+		case "{path}":
+			return e.Path
+		case "{rule}":
+			return e.Rule
+		case "{param}":
+			return e.Param
+		}
+		return fmt.Sprint(e.Value)
+	})
+}
+
+// Unwrap returns the error returned by the rule.
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// TagError is the error reported for a tag that cannot be used, such as
+// one naming an unknown rule or giving a rule a parameter it cannot parse.
+// It wraps ErrUnknownTag or ErrBadParameter, which errors.Is can test, or
+// ErrUnsupported when Check finds a rule applied to a field of a kind it
+// does not support. Validate and Valid report the error it wraps instead,
+// unless SetFieldErrors asks for FieldErrors.
+type TagError struct {
+	// Type is the struct type holding the field. It is nil for
+	// tags given to Valid.
+	Type reflect.Type
+	// Field is the name of the Go struct field.
+	Field string
+	// Tag is the full tag of the field.
+	Tag string
+	// Rule is the name of the rule at fault, as written in the tag.
+	Rule string
+	// Offset is the byte offset within Tag of the rule name, or of
+	// its parameter when the parameter is at fault.
+	Offset int
+	// Err is ErrUnknownTag, or the error telling why the rule
+	// cannot be used, such as ErrBadParameter.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *TagError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: rule %q at offset %d of tag %q", e.Err, e.Rule, e.Offset, e.Tag)
+	if e.Type != nil {
+		fmt.Fprintf(&b, " on field %s.%s", e.Type, e.Field)
+	}
+	return b.String()
+}
+
+// Unwrap returns ErrUnknownTag or the error returned by the rule.
+func (e *TagError) Unwrap() error {
+	return e.Err
+}
+
 // ValidationFunc is a function that receives the value of a
 // field and a parameter used for the respective validation tag.
 type ValidationFunc func(v interface{}, param string) error
 
-// Validator implements a validator
+// ValidationFuncContext is like ValidationFunc but also receives the
+// context given to ValidateContext or ValidContext, which can carry
+// deadlines or request-scoped data.
+type ValidationFuncContext func(ctx context.Context, v interface{}, param string) error
+
+// fieldFunc is a validation function that is also given the context
+// and the values surrounding the field, for rules comparing the field
+// to others.
+type fieldFunc func(v interface{}, param string, fc fieldContext) error
+
+// Validator implements a validator. It is safe for concurrent use: its
+// configuration is an immutable snapshot that the Set methods replace as a
+// whole, so a validation in progress keeps using the configuration it
+// started with while another goroutine changes it.
 type Validator struct {
+	// mu serializes changes to the configuration.
+	mu sync.Mutex
+	// cfg holds the current *config.
+	cfg atomic.Value
+}
+
+// config is a snapshot of the configuration of a Validator. Once
+// stored in a Validator it is never modified; changes are made to
+// a copy that replaces it.
+type config struct {
 	// validationFuncs is a map of ValidationFuncs indexed
 	// by their name.
 	validationFuncs map[string]ValidationFunc
+	// fieldFuncs is a map of the rules that need the context
+	// or access to other fields, indexed by their name.
+	fieldFuncs map[string]fieldFunc
+	// checkFuncs are used by Check to check the rules of the
+	// same name without a value, indexed by their name.
+	checkFuncs map[string]checkFunc
+	// schemaFuncs write the rules of the same name to JSON Schema,
+	// indexed by their name.
+	schemaFuncs map[string]SchemaFunc
 	// Tag name being used.
 	tagName string
 	// printJSON set to true will make errors print with the
 	// name of their json field instead of their struct tag.
 	// If no json tag is present the name of the struct field is used.
 	printJSON bool
+	// nameFunc names the struct fields in the paths of errors,
+	// see SetNameFunc. Go field names are used when it is nil.
+	nameFunc func(reflect.StructField) string
+	// flattenEmbedded set to true names the fields of embedded structs
+	// nameFunc gives no name as fields of the embedding struct.
+	flattenEmbedded bool
+	// plans caches the compiled validation plans of the struct
+	// types validated so far.
+	plans *planCache
+	// callValidate set to true will make the methods of Validatable
+	// and ContextValidatable values be called.
+	callValidate bool
+	// skipSelf is set on the Validator given to ContextValidatable
+	// values so validating themselves does not call them again.
+	skipSelf reflect.Type
+	// reportCycles set to true will make values referring back to
+	// themselves be reported with ErrCycle instead of being skipped.
+	reportCycles bool
+	// maxDepth is the maximum number of nested structs walked,
+	// or 0 for no limit.
+	maxDepth int
+	// generated is set when the ValidateFields methods written by
+	// validatorgen can be used in place of the tags they were
+	// generated from, see useGenerated.
+	generated bool
+	// registered holds the rules set with RegisterRules and
+	// MergeRules, indexed by the type they were registered for
+	// and the struct type declaring the field, then by field name.
+	// The maps are replaced, never modified.
+	registered map[registeredKey]map[string]registeredRules
+	// ruleScopes holds the types having rules registered through
+	// nested paths, which only apply within their values.
+	ruleScopes map[reflect.Type]bool
+	// translator translates the errors given to Translate before
+	// the bundled catalogs, if set.
+	translator Translator
+	// labelFunc returns the labels of struct fields, reported in
+	// FieldErrors. The label tag is used when it is nil.
+	labelFunc func(reflect.StructField) string
+	// useFieldErrors set to true reports the errors of rules as
+	// *FieldErrors instead of the errors returned by the rules.
+	useFieldErrors bool
 }
 
 // Helper validator so users can use the
 // functions directly from the package
-var defaultValidator = NewValidator()
+var defaultValidator = func() *Validator {
+	v := NewValidator()
+	// generated methods call back the default validator for the rules
+	// they do not implement, so only it can use them
+	v.update(func(cfg *config) {
+		cfg.generated = true
+	})
+	return v
+}()
 
 // NewValidator creates a new Validator
 func NewValidator() *Validator {
-	return &Validator{
+	return newValidator(&config{
 		tagName: "validate",
 		validationFuncs: map[string]ValidationFunc{
 			"nonzero": nonzero,
@@ -141,8 +399,107 @@ func NewValidator() *Validator {
 			"regexp":  regex,
 			"nonnil":  nonnil,
 		},
-		printJSON: false,
+		fieldFuncs: map[string]fieldFunc{
+			"eqfield":  eqfield,
+			"nefield":  nefield,
+			"gtfield":  gtfield,
+			"gtefield": gtefield,
+			"ltfield":  ltfield,
+			"ltefield": ltefield,
+
+			"required_if":      requiredIf,
+			"required_unless":  requiredUnless,
+			"required_with":    requiredWith,
+			"required_without": requiredWithout,
+		},
+		checkFuncs: map[string]checkFunc{
+			"nonzero": probe(nonzero),
+			"len":     probe(length),
+			"min":     probe(min),
+			"max":     probe(max),
+			"regexp":  probe(regex),
+			"nonnil":  probe(nonnil),
+
+			"eqfield":  checkCompareField(false),
+			"nefield":  checkCompareField(false),
+			"gtfield":  checkCompareField(true),
+			"gtefield": checkCompareField(true),
+			"ltfield":  checkCompareField(true),
+			"ltefield": checkCompareField(true),
+
+			"required_if":      checkFieldsMatch,
+			"required_unless":  checkFieldsMatch,
+			"required_with":    checkFieldsPresent,
+			"required_without": checkFieldsPresent,
+		},
+		schemaFuncs: map[string]SchemaFunc{
+			"nonzero": schemaNonzero,
+			"len":     schemaRange(true, true),
+			"min":     schemaRange(true, false),
+			"max":     schemaRange(false, true),
+			"regexp":  schemaRegexp,
+			"nonnil":  schemaNonnil,
+		},
+		plans: newPlanCache(),
+	})
+}
+
+// newValidator creates a Validator using the configuration cfg.
+func newValidator(cfg *config) *Validator {
+	v := &Validator{}
+	v.cfg.Store(cfg)
+	return v
+}
+
+// zeroConfig is the configuration of a zero Validator.
+var zeroConfig = &config{
+	validationFuncs: map[string]ValidationFunc{},
+	fieldFuncs:      map[string]fieldFunc{},
+	checkFuncs:      map[string]checkFunc{},
+	schemaFuncs:     map[string]SchemaFunc{},
+	plans:           newPlanCache(),
+}
+
+// config returns the current configuration snapshot.
+func (mv *Validator) config() *config {
+	if cfg, ok := mv.cfg.Load().(*config); ok {
+		return cfg
 	}
+	return zeroConfig
+}
+
+// update replaces the configuration with a copy modified by fn. The
+// copy starts with an empty plan cache as plans depend on the
+// configuration.
+func (mv *Validator) update(fn func(cfg *config)) {
+	mv.mu.Lock()
+	defer mv.mu.Unlock()
+	cfg := mv.config().clone()
+	fn(cfg)
+	mv.cfg.Store(cfg)
+}
+
+// clone returns a copy of cfg with its own maps and an empty plan cache.
+func (cfg *config) clone() *config {
+	c := *cfg
+	c.validationFuncs = make(map[string]ValidationFunc, len(cfg.validationFuncs))
+	for k, f := range cfg.validationFuncs {
+		c.validationFuncs[k] = f
+	}
+	c.fieldFuncs = make(map[string]fieldFunc, len(cfg.fieldFuncs))
+	for k, f := range cfg.fieldFuncs {
+		c.fieldFuncs[k] = f
+	}
+	c.checkFuncs = make(map[string]checkFunc, len(cfg.checkFuncs))
+	for k, f := range cfg.checkFuncs {
+		c.checkFuncs[k] = f
+	}
+	c.schemaFuncs = make(map[string]SchemaFunc, len(cfg.schemaFuncs))
+	for k, f := range cfg.schemaFuncs {
+		c.schemaFuncs[k] = f
+	}
+	c.plans = newPlanCache()
+	return &c
 }
 
 // SetTag allows you to change the tag name used in structs
@@ -152,7 +509,9 @@ func SetTag(tag string) {
 
 // SetTag allows you to change the tag name used in structs
 func (mv *Validator) SetTag(tag string) {
-	mv.tagName = tag
+	mv.update(func(cfg *config) {
+		cfg.tagName = tag
+	})
 }
 
 // WithTag creates a new Validator with the new tag name. It is
@@ -176,9 +535,13 @@ func SetPrintJSON(printJSON bool) {
 	defaultValidator.SetPrintJSON(printJSON)
 }
 
-// SetPrintJSON allows you to print errors with json tag names present in struct tags
+// SetPrintJSON allows you to print errors with json tag names present in struct tags.
+// It replaces the function set with SetNameFunc, if any.
 func (mv *Validator) SetPrintJSON(printJSON bool) {
-	mv.printJSON = printJSON
+	mv.update(func(cfg *config) {
+		cfg.printJSON = printJSON
+		cfg.nameFunc = nil
+	})
 }
 
 // WithPrintJSON creates a new Validator with printJSON set to new value. It is
@@ -197,17 +560,130 @@ func (mv *Validator) WithPrintJSON(printJSON bool) *Validator {
 	return v
 }
 
+// SetReportCycles allows you to report values referring back to themselves
+// with ErrCycle instead of silently skipping them
+func SetReportCycles(reportCycles bool) {
+	defaultValidator.SetReportCycles(reportCycles)
+}
+
+// SetReportCycles allows you to report values referring back to themselves
+// with ErrCycle instead of silently skipping them. Either way, a pointer,
+// map or slice found again while walking the values it refers to is not
+// walked a second time.
+func (mv *Validator) SetReportCycles(reportCycles bool) {
+	mv.update(func(cfg *config) {
+		cfg.reportCycles = reportCycles
+	})
+}
+
+// WithReportCycles creates a new Validator with reportCycles set to new value.
+func WithReportCycles(reportCycles bool) *Validator {
+	return defaultValidator.WithReportCycles(reportCycles)
+}
+
+// WithReportCycles creates a new Validator with reportCycles set to new value.
+func (mv *Validator) WithReportCycles(reportCycles bool) *Validator {
+	v := mv.copy()
+	v.SetReportCycles(reportCycles)
+	return v
+}
+
+// SetMaxDepth sets the maximum number of nested structs walked
+func SetMaxDepth(depth int) {
+	defaultValidator.SetMaxDepth(depth)
+}
+
+// SetMaxDepth sets the maximum number of nested structs walked, the value
+// given to Validate being at depth 1. Structs nested deeper are not
+// validated and ErrMaxDepth is reported in their place. A depth of 0,
+// the default, means no limit.
+func (mv *Validator) SetMaxDepth(depth int) {
+	mv.update(func(cfg *config) {
+		cfg.maxDepth = depth
+	})
+}
+
+// WithMaxDepth creates a new Validator with the new maximum depth.
+func WithMaxDepth(depth int) *Validator {
+	return defaultValidator.WithMaxDepth(depth)
+}
+
+// WithMaxDepth creates a new Validator with the new maximum depth.
+func (mv *Validator) WithMaxDepth(depth int) *Validator {
+	v := mv.copy()
+	v.SetMaxDepth(depth)
+	return v
+}
+
+// SetLabelFunc sets the function giving the labels of struct fields
+func SetLabelFunc(f func(reflect.StructField) string) {
+	defaultValidator.SetLabelFunc(f)
+}
+
+// SetLabelFunc sets the function giving the labels of struct fields,
+// reported in FieldErrors and used in their messages. A field has no
+// label when it returns "". By default, and when f is nil, the label is
+// given by the label tag of the field (e.g. label:"Date of birth").
+func (mv *Validator) SetLabelFunc(f func(reflect.StructField) string) {
+	mv.update(func(cfg *config) {
+		cfg.labelFunc = f
+	})
+}
+
+// WithLabelFunc creates a new Validator with the new label function.
+func WithLabelFunc(f func(reflect.StructField) string) *Validator {
+	return defaultValidator.WithLabelFunc(f)
+}
+
+// WithLabelFunc creates a new Validator with the new label function.
+func (mv *Validator) WithLabelFunc(f func(reflect.StructField) string) *Validator {
+	v := mv.copy()
+	v.SetLabelFunc(f)
+	return v
+}
+
+// SetFieldErrors sets whether the errors of rules are reported as *FieldErrors
+func SetFieldErrors(fieldErrors bool) {
+	defaultValidator.SetFieldErrors(fieldErrors)
+}
+
+// SetFieldErrors sets whether the errors of rules are reported as
+// *FieldErrors, recording the field, rule and value at fault, instead of
+// the errors returned by the rules such as ErrMin. The errors of rules
+// given a message or code in their tag are always *FieldErrors.
+func (mv *Validator) SetFieldErrors(fieldErrors bool) {
+	mv.update(func(cfg *config) {
+		cfg.useFieldErrors = fieldErrors
+	})
+}
+
+// WithFieldErrors creates a new Validator with fieldErrors set to new value.
+func WithFieldErrors(fieldErrors bool) *Validator {
+	return defaultValidator.WithFieldErrors(fieldErrors)
+}
+
+// WithFieldErrors creates a new Validator with fieldErrors set to new value.
+// It is useful to chain-call with Validate:
+// validator.WithFieldErrors(true).Validate(t)
+func (mv *Validator) WithFieldErrors(fieldErrors bool) *Validator {
+	v := mv.copy()
+	v.SetFieldErrors(fieldErrors)
+	return v
+}
+
+// LabelTag returns a label function, for SetLabelFunc, giving the labels
+// of struct fields by their tag of the given name.
+func LabelTag(name string) func(reflect.StructField) string {
+	return func(fieldDef reflect.StructField) string {
+		return fieldDef.Tag.Get(name)
+	}
+}
+
 // Copy a validator
 func (mv *Validator) copy() *Validator {
-	newFuncs := map[string]ValidationFunc{}
-	for k, f := range mv.validationFuncs {
-		newFuncs[k] = f
-	}
-	return &Validator{
-		tagName:         mv.tagName,
-		validationFuncs: newFuncs,
-		printJSON:       mv.printJSON,
-	}
+	cfg := mv.config().clone()
+	cfg.generated = false
+	return newValidator(cfg)
 }
 
 // SetValidationFunc sets the function to be used for a given
@@ -220,15 +696,71 @@ func SetValidationFunc(name string, vf ValidationFunc) error {
 // SetValidationFunc sets the function to be used for a given
 // validation constraint. Calling this function with nil vf
 // is the same as removing the constraint function from the list.
+// The keywords of the tag syntax, such as dive or msg, cannot be used
+// as names.
 func (mv *Validator) SetValidationFunc(name string, vf ValidationFunc) error {
+	if err := checkFuncName(name); err != nil {
+		return err
+	}
+	mv.update(func(cfg *config) {
+		if _, builtin := cfg.checkFuncs[name]; builtin {
+			cfg.generated = false
+			delete(cfg.schemaFuncs, name)
+		}
+		// a function set by the user takes the place of any builtin
+		// field function of the same name
+		delete(cfg.fieldFuncs, name)
+		delete(cfg.checkFuncs, name)
+		if vf == nil {
+			delete(cfg.validationFuncs, name)
+		} else {
+			cfg.validationFuncs[name] = vf
+		}
+	})
+	return nil
+}
+
+// SetValidationFuncContext is like SetValidationFunc but sets a function
+// that also receives the context of the validation.
+func SetValidationFuncContext(name string, vf ValidationFuncContext) error {
+	return defaultValidator.SetValidationFuncContext(name, vf)
+}
+
+// SetValidationFuncContext is like SetValidationFunc but sets a function
+// that also receives the context of the validation. It replaces any function
+// of the same name, whether or not it takes a context, and calling it with
+// nil vf removes the constraint function from the list.
+func (mv *Validator) SetValidationFuncContext(name string, vf ValidationFuncContext) error {
+	if err := checkFuncName(name); err != nil {
+		return err
+	}
+	mv.update(func(cfg *config) {
+		if _, builtin := cfg.checkFuncs[name]; builtin {
+			delete(cfg.schemaFuncs, name)
+		}
+		cfg.generated = false
+		delete(cfg.validationFuncs, name)
+		delete(cfg.checkFuncs, name)
+		if vf == nil {
+			delete(cfg.fieldFuncs, name)
+		} else {
+			cfg.fieldFuncs[name] = func(v interface{}, param string, fc fieldContext) error {
+				return vf(fc.ctx, v, param)
+			}
+		}
+	})
+	return nil
+}
+
+// checkFuncName returns an error if name cannot be the name of a
+// validation function.
+func checkFuncName(name string) error {
 	if name == "" {
 		return errors.New("name cannot be empty")
 	}
-	if vf == nil {
-		delete(mv.validationFuncs, name)
-		return nil
+	if tagsyntax.IsKeyword(name) {
+		return fmt.Errorf("name %q is a keyword of the tag syntax", name)
 	}
-	mv.validationFuncs[name] = vf
 	return nil
 }
 
@@ -240,131 +772,289 @@ func Validate(v interface{}) error {
 // Validate validates the fields of structs (included embedded structs) based on
 // 'validator' tags and returns errors found indexed by the field name.
 func (mv *Validator) Validate(v interface{}) error {
-	m := make(ErrorMap)
-	mv.deepValidateCollection(reflect.ValueOf(v), m, func() string {
-		return ""
-	})
-	if len(m) > 0 {
-		return m
+	return mv.ValidateContext(context.Background(), v)
+}
+
+// ValidateContext calls the ValidateContext method on the default validator.
+func ValidateContext(ctx context.Context, v interface{}) error {
+	return defaultValidator.ValidateContext(ctx, v)
+}
+
+// ValidateContext is like Validate but passes ctx to the functions set with
+// SetValidationFuncContext and to ContextValidatable values. If ctx is done
+// before the validation ends, the walk stops and ctx.Err() is returned.
+// The errors are translated when ctx carries a locale, see ContextWithLocale.
+func (mv *Validator) ValidateContext(ctx context.Context, v interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	cfg := mv.config()
+	rv := reflect.ValueOf(v)
+	vs := &validationState{
+		m:           make(ErrorMap),
+		root:        indirect(rv),
+		ctx:         ctx,
+		skipSelf:    cfg.skipSelf,
+		fieldErrors: cfg.reportFieldErrors(ctx),
+	}
+	cfg.deepValidateCollection(rv, vs, "")
+	if vs.err != nil {
+		return vs.err
+	}
+	if len(vs.m) > 0 {
+		return cfg.translateContext(ctx, vs.m)
 	}
 	return nil
 }
 
-func (mv *Validator) validateStruct(sv reflect.Value, m ErrorMap) error {
+// validationState holds the state of a single call to Validate.
+type validationState struct {
+	// m collects the errors found so far.
+	m ErrorMap
+	// root is the value given to Validate, with pointers
+	// and interfaces dereferenced.
+	root reflect.Value
+	// ctx is the context of the validation.
+	ctx context.Context
+	// err is set to the context error once ctx is done.
+	err error
+	// skipSelf is the type of the receiver whose Validate method
+	// must not be called when it is the root value.
+	skipSelf reflect.Type
+	// walking holds the pointers, maps and slices being walked,
+	// to detect values referring back to themselves.
+	walking map[walkKey]struct{}
+	// depth is the number of nested structs being walked.
+	depth int
+	// fieldErrors is set when the errors of rules are reported
+	// as *FieldErrors.
+	fieldErrors bool
+	// scopes are the struct types being walked that have rules
+	// registered through nested paths, outermost first.
+	scopes []reflect.Type
+}
+
+// walkKey identifies a pointer, map or slice. The type is part of
+// the key as a struct and its first field share the same address.
+type walkKey struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// enter marks the pointer, map or slice f as being walked. It reports
+// false if it already is, meaning f refers back to itself.
+func (vs *validationState) enter(f reflect.Value) bool {
+	k := walkKey{f.Pointer(), f.Type()}
+	if _, found := vs.walking[k]; found {
+		return false
+	}
+	if vs.walking == nil {
+		vs.walking = make(map[walkKey]struct{})
+	}
+	vs.walking[k] = struct{}{}
+	return true
+}
+
+// leave marks f as no longer being walked.
+func (vs *validationState) leave(f reflect.Value) {
+	delete(vs.walking, walkKey{f.Pointer(), f.Type()})
+}
+
+// fieldContext gives field funcs access to the values
+// surrounding the field being validated.
+type fieldContext struct {
+	// parent is the struct holding the field. It is invalid
+	// for values validated with Valid.
+	parent reflect.Value
+	// root is the value given to Validate.
+	root reflect.Value
+	// ctx is the context of the validation.
+	ctx context.Context
+	// fieldErrors is set when the errors of rules are reported
+	// as *FieldErrors.
+	fieldErrors bool
+}
+
+// reportFieldErrors reports whether the errors of rules are reported as
+// *FieldErrors when validating with ctx: when SetFieldErrors asks for
+// them or ctx carries a locale to translate them to.
+func (cfg *config) reportFieldErrors(ctx context.Context) bool {
+	return cfg.useFieldErrors || LocaleFromContext(ctx) != ""
+}
+
+// done reports whether the context of the validation is done,
+// in which case the walk must stop.
+func (vs *validationState) done() bool {
+	if vs.err == nil {
+		vs.err = vs.ctx.Err()
+	}
+	return vs.err != nil
+}
+
+// indirect dereferences pointers and interfaces until it reaches
+// a value that is neither, or a nil one.
+func indirect(v reflect.Value) reflect.Value {
+	for (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+func (cfg *config) validateStruct(sv reflect.Value, vs *validationState, path string) error {
 	kind := sv.Kind()
 	if (kind == reflect.Ptr || kind == reflect.Interface) && !sv.IsNil() {
-		return mv.validateStruct(sv.Elem(), m)
+		return cfg.validateStruct(sv.Elem(), vs, path)
 	}
 	if kind != reflect.Struct && kind != reflect.Interface {
 		return ErrUnsupported
 	}
 
 	st := sv.Type()
-	nfields := st.NumField()
-	for i := 0; i < nfields; i++ {
-		if err := mv.validateField(st.Field(i), sv.Field(i), m); err != nil {
-			return err
+	p := cfg.structPlan(st, cfg.ruleScope(vs.scopes, st))
+	scopes := vs.scopes
+	vs.scopes = cfg.enterScope(scopes, st)
+	var err error
+	if p.generated && sv.CanInterface() && !vs.fieldErrors {
+		cfg.validateGenerated(p, sv, vs, path)
+	} else {
+		for i := range p.fields {
+			if err = cfg.validateField(&p.fields[i], sv, vs, path); err != nil {
+				break
+			}
 		}
 	}
-
-	return nil
+	vs.scopes = scopes
+	return err
 }
 
-// validateField validates the field of fieldVal referred to by fieldDef.
-// If fieldDef refers to an anonymous/embedded field,
+// validateField validates the field of struct sv described by fp.
+// If fp refers to an anonymous/embedded field,
 // validateField will walk all of the embedded type's fields and validate them on sv.
-func (mv *Validator) validateField(fieldDef reflect.StructField, fieldVal reflect.Value, m ErrorMap) error {
-	tag := fieldDef.Tag.Get(mv.tagName)
-	if tag == "-" {
-		return nil
-	}
-	// deal with pointers
-	for (fieldVal.Kind() == reflect.Ptr || fieldVal.Kind() == reflect.Interface) && !fieldVal.IsNil() {
-		fieldVal = fieldVal.Elem()
+func (cfg *config) validateField(fp *fieldPlan, sv reflect.Value, vs *validationState, path string) error {
+	fn := joinPath(path, fp.name)
+	errs, omitted := cfg.fieldErrors(fp, sv, vs, fn)
+
+	if fp.recurse && !omitted {
+		// no-op if field is not a struct, interface, array, slice or map;
+		// pointers are left for it to follow so it can detect cycles
+		cfg.deepValidateCollection(sv.Field(fp.index), vs, fn)
 	}
 
-	// ignore private structs unless Anonymous
-	if !fieldDef.Anonymous && fieldDef.PkgPath != "" {
-		return nil
+	if len(errs) > 0 {
+		vs.m[fn] = append(vs.m[fn], errs...)
 	}
+	return nil
+}
 
-	var errs ErrorArray
-	if tag != "" {
-		var err error
-		if fieldDef.PkgPath != "" {
-			err = ErrCannotValidate
-		} else {
-			err = mv.validValue(fieldVal, tag)
-		}
-		if errarr, ok := err.(ErrorArray); ok {
-			errs = errarr
-		} else if err != nil {
-			errs = ErrorArray{err}
+// fieldErrors runs the rules of the field of struct sv described by fp,
+// whose path is fn, and returns the errors found for the field itself.
+// Those of the elements its rules dive into are added to vs.m. It reports
+// whether omitempty stopped the rules, so the field is not walked either.
+func (cfg *config) fieldErrors(fp *fieldPlan, sv reflect.Value, vs *validationState, fn string) (ErrorArray, bool) {
+	if fp.err != nil {
+		if !vs.fieldErrors {
+			return ErrorArray{tagCause(fp.err)}, false
 		}
+		return ErrorArray{fp.err}, false
 	}
-
-	// no-op if field is not a struct, interface, array, slice or map
-	fn := mv.fieldName(fieldDef)
-	mv.deepValidateCollection(fieldVal, m, func() string {
-		return fn
-	})
-
-	if len(errs) > 0 {
-		m[fn] = errs
+	if fp.rules == nil {
+		return nil, false
 	}
-	return nil
+	// deal with pointers
+	fieldVal := indirect(sv.Field(fp.index))
+	return cfg.runRules(fp.rules, fieldVal, fieldContext{parent: sv, root: vs.root, ctx: vs.ctx, fieldErrors: vs.fieldErrors}, vs.m, fn, fp)
 }
 
-func (mv *Validator) fieldName(fieldDef reflect.StructField) string {
-	if mv.printJSON {
+// fieldName returns the name of fieldDef in the paths of errors. It is
+// empty for an embedded struct the name function gives no name when
+// SetFlattenEmbedded is set, whose fields are then named as fields of
+// the embedding struct.
+func (cfg *config) fieldName(fieldDef reflect.StructField) string {
+	if cfg.printJSON {
 		if jsonTagValue, ok := fieldDef.Tag.Lookup("json"); ok {
 			return parseName(jsonTagValue)
 		}
 	}
+	if cfg.nameFunc == nil {
+		return fieldDef.Name
+	}
+	if name := cfg.nameFunc(fieldDef); name != "" || (cfg.flattenEmbedded && fieldDef.Anonymous) {
+		return name
+	}
 	return fieldDef.Name
 }
 
-func (mv *Validator) deepValidateCollection(f reflect.Value, m ErrorMap, fnameFn func() string) {
+// joinPath appends the field name to the path of its parent.
+func joinPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	if name == "" {
+		return parent
+	}
+	return parent + "." + name
+}
+
+func (cfg *config) deepValidateCollection(f reflect.Value, vs *validationState, path string) {
+	if vs.done() {
+		return
+	}
+	if k := f.Kind(); k != reflect.Interface && k != reflect.Ptr && k != reflect.Invalid {
+		cfg.validateSelf(f, vs, path)
+	}
+	switch f.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if f.IsNil() {
+			return
+		}
+		if !vs.enter(f) {
+			if cfg.reportCycles {
+				vs.m[path] = append(vs.m[path], ErrCycle)
+			}
+			return
+		}
+		defer vs.leave(f)
+	}
 	switch f.Kind() {
 	case reflect.Interface, reflect.Ptr:
 		if f.IsNil() {
 			return
 		}
-		mv.deepValidateCollection(f.Elem(), m, fnameFn)
+		cfg.deepValidateCollection(f.Elem(), vs, path)
 	case reflect.Struct:
-		subm := make(ErrorMap)
-		err := mv.validateStruct(f, subm)
-		parentName := fnameFn()
-		if err != nil {
-			m[parentName] = ErrorArray{err}
-		}
-		for j, k := range subm {
-			keyName := j
-			if parentName != "" {
-				keyName = parentName + "." + keyName
-			}
-			m[keyName] = k
+		if cfg.maxDepth > 0 && vs.depth >= cfg.maxDepth {
+			vs.m[path] = append(vs.m[path], ErrMaxDepth)
+			return
+		}
+		vs.depth++
+		defer func() { vs.depth-- }()
+		if err := cfg.validateStruct(f, vs, path); err != nil {
+			vs.m[path] = ErrorArray{err}
 		}
 	case reflect.Array, reflect.Slice:
 		// we don't need to loop over every byte in a byte slice so we only end up
 		// looping when the kind is something we care about
-		switch f.Type().Elem().Kind() {
-		case reflect.Struct, reflect.Interface, reflect.Ptr, reflect.Map, reflect.Array, reflect.Slice:
-			for i := 0; i < f.Len(); i++ {
-				mv.deepValidateCollection(f.Index(i), m, func() string {
-					return fmt.Sprintf("%s[%d]", fnameFn(), i)
-				})
+		if cfg.mayRecurse(f.Type().Elem()) {
+			for i := 0; i < f.Len() && !vs.done(); i++ {
+				cfg.deepValidateCollection(f.Index(i), vs, fmt.Sprintf("%s[%d]", path, i))
 			}
 		}
 	case reflect.Map:
+		keys, values := cfg.mayRecurse(f.Type().Key()), cfg.mayRecurse(f.Type().Elem())
+		if !keys && !values {
+			return
+		}
 		for _, key := range f.MapKeys() {
-			mv.deepValidateCollection(key, m, func() string {
-				return fmt.Sprintf("%s[%+v](key)", fnameFn(), key.Interface())
-			}) // validate the map key
-			value := f.MapIndex(key)
-			mv.deepValidateCollection(value, m, func() string {
-				return fmt.Sprintf("%s[%+v](value)", fnameFn(), key.Interface())
-			})
+			if vs.done() {
+				return
+			}
+			if keys {
+				// validate the map key
+				cfg.deepValidateCollection(key, vs, fmt.Sprintf("%s[%+v](key)", path, key.Interface()))
+			}
+			if values {
+				cfg.deepValidateCollection(f.MapIndex(key), vs, fmt.Sprintf("%s[%+v](value)", path, key.Interface()))
+			}
 		}
 	}
 }
@@ -378,93 +1068,381 @@ func Valid(val interface{}, tags string) error {
 // Valid validates a value based on the provided
 // tags and returns errors found or nil.
 func (mv *Validator) Valid(val interface{}, tags string) error {
+	return mv.ValidContext(context.Background(), val, tags)
+}
+
+// ValidContext calls the ValidContext method on the default validator.
+func ValidContext(ctx context.Context, val interface{}, tags string) error {
+	return defaultValidator.ValidContext(ctx, val, tags)
+}
+
+// ValidContext is like Valid but passes ctx to the functions set with
+// SetValidationFuncContext. If ctx is done before the validation ends,
+// ctx.Err() is returned. The errors are translated when ctx carries a
+// locale, see ContextWithLocale.
+func (mv *Validator) ValidContext(ctx context.Context, val interface{}, tags string) error {
 	if tags == "-" {
 		return nil
 	}
+	cfg := mv.config()
 	v := reflect.ValueOf(val)
 	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && !v.IsNil() {
-		return mv.validValue(v.Elem(), tags)
+		return cfg.validValue(ctx, v.Elem(), tags)
 	}
 	if v.Kind() == reflect.Invalid {
-		return mv.validateVar(nil, tags)
+		return cfg.validateVar(ctx, nil, tags)
 	}
-	return mv.validateVar(val, tags)
+	return cfg.validateVar(ctx, val, tags)
 }
 
 // validValue is like Valid but takes a Value instead of an interface
-func (mv *Validator) validValue(v reflect.Value, tags string) error {
+func (cfg *config) validValue(ctx context.Context, v reflect.Value, tags string) error {
+	return cfg.validateVar(ctx, valueInterface(v), tags)
+}
+
+// valueInterface returns the value held by v, or nil if v is invalid.
+func valueInterface(v reflect.Value) interface{} {
 	if v.Kind() == reflect.Invalid {
-		return mv.validateVar(nil, tags)
+		return nil
 	}
-	return mv.validateVar(v.Interface(), tags)
+	return v.Interface()
 }
 
 // validateVar validates one single variable
-func (mv *Validator) validateVar(v interface{}, tag string) error {
-	tags, err := mv.parseTags(tag)
+func (cfg *config) validateVar(ctx context.Context, v interface{}, tag string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	fieldErrors := cfg.reportFieldErrors(ctx)
+	rs, err := cfg.parseTags(tag)
 	if err != nil {
 		// unknown tag found, give up.
+		if !fieldErrors {
+			return tagCause(err)
+		}
 		return err
 	}
-	errs := make(ErrorArray, 0, len(tags))
-	for _, t := range tags {
-		if err := t.Fn(v, t.Param); err != nil {
-			errs = append(errs, err)
+	m := make(ErrorMap)
+	errs, _ := cfg.runRules(rs, reflect.ValueOf(v), fieldContext{ctx: ctx, fieldErrors: fieldErrors}, m, "", nil)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(m) > 0 {
+		// errors of collection elements follow those of the value
+		// itself, sorted by path
+		paths := make([]string, 0, len(m))
+		for p := range m {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		for _, p := range paths {
+			errs = append(errs, m[p]...)
 		}
 	}
 	if len(errs) > 0 {
-		return errs
+		return cfg.translateContext(ctx, errs)
 	}
 	return nil
 }
 
-// tag represents one of the tag items
-type tag struct {
-	Name  string         // name of the tag
-	Fn    ValidationFunc // validation function to call
-	Param string         // parameter to send to the validation function
+// runRules runs the rules of rs against val and returns the errors
+// found. If rs dives into the elements of val, their errors are added
+// to m under the path of each element. When fp is set, the errors are
+// attributed to the struct field it describes. Unless fc asks for
+// *FieldErrors, the errors returned by the rules are reported as they
+// are, except for those given a message or code in the tag. It reports
+// whether omitempty stopped the rules, as val is empty.
+func (cfg *config) runRules(rs *ruleSet, val reflect.Value, fc fieldContext, m ErrorMap, path string, fp *fieldPlan) (ErrorArray, bool) {
+	errs, omitted := cfg.runFieldRules(rs, val, fc, m, path, fp)
+	if !fc.fieldErrors {
+		for i, e := range errs {
+			if fe := e.(*FieldError); fe.Message == "" && fe.Code == "" {
+				errs[i] = tagCause(fe.Err)
+			}
+		}
+	}
+	return errs, omitted
+}
+
+// tagCause returns the error wrapped by err when it is a *TagError, as
+// reported without FieldErrors, and err otherwise.
+func tagCause(err error) error {
+	if te, ok := err.(*TagError); ok {
+		return te.Err
+	}
+	return err
+}
+
+// runFieldRules is runRules reporting all errors as *FieldErrors.
+func (cfg *config) runFieldRules(rs *ruleSet, val reflect.Value, fc fieldContext, m ErrorMap, path string, fp *fieldPlan) (ErrorArray, bool) {
+	errs, omitted := cfg.runTags(rs, valueInterface(val), fc)
+	for _, e := range errs {
+		fe := e.(*FieldError)
+		fe.Path = path
+		if fp != nil {
+			fe.Field, fe.Name, fe.JSONName, fe.Label = fp.field, fp.name, fp.jsonName, fp.label
+			if te, ok := fe.Err.(*TagError); ok {
+				te.Type, te.Field = fc.parent.Type(), fp.field
+			}
+		}
+		fillExprErrors(fe, fc)
+		if fe.Message != "" {
+			// the message holds the template until the path is known
+			fe.Message = fe.expandMessage(fe.Message)
+		}
+	}
+	if (rs.elems == nil && rs.keys == nil) || omitted || isNilOrInvalid(val) {
+		return errs, omitted
+	}
+
+	switch val.Kind() {
+	case reflect.Array, reflect.Slice:
+		if rs.elems == nil {
+			break
+		}
+		for i := 0; i < val.Len() && fc.ctx.Err() == nil; i++ {
+			ep := fmt.Sprintf("%s[%d]", path, i)
+			if elemErrs, _ := cfg.runRules(rs.elems, indirect(val.Index(i)), fc, m, ep, fp); len(elemErrs) > 0 {
+				m[ep] = elemErrs
+			}
+		}
+	case reflect.Map:
+		for _, key := range val.MapKeys() {
+			if fc.ctx.Err() != nil {
+				break
+			}
+			if rs.keys != nil {
+				kp := fmt.Sprintf("%s[%+v](key)", path, key.Interface())
+				if keyErrs, _ := cfg.runRules(rs.keys, indirect(key), fc, m, kp, fp); len(keyErrs) > 0 {
+					m[kp] = keyErrs
+				}
+			}
+			if rs.elems != nil {
+				vp := fmt.Sprintf("%s[%+v](value)", path, key.Interface())
+				if valueErrs, _ := cfg.runRules(rs.elems, indirect(val.MapIndex(key)), fc, m, vp, fp); len(valueErrs) > 0 {
+					m[vp] = valueErrs
+				}
+			}
+		}
+	default:
+		fe := &FieldError{Path: path, Rule: diveTag, Value: valueInterface(val), Err: ErrUnsupported}
+		if fp != nil {
+			fe.Field, fe.Name, fe.JSONName, fe.Label = fp.field, fp.name, fp.jsonName, fp.label
+		}
+		errs = append(errs, fe)
+	}
+	return errs, false
 }
 
-// separate by no escaped commas
-var sepPattern *regexp.Regexp = regexp.MustCompile(`((?:^|[^\\])(?:\\\\)*),`)
+// runTags runs the already parsed tags of rs against v. Every failure
+// is reported as a *FieldError, wrapping a *TagError when the rule
+// could not use its parameter. It reports whether omitempty stopped
+// the rules, as v is empty.
+func (cfg *config) runTags(rs *ruleSet, v interface{}, fc fieldContext) (ErrorArray, bool) {
+	var errs ErrorArray
+	for i := range rs.tags {
+		t := &rs.tags[i]
+		if t.Name == omitEmptyTag {
+			if isEmpty(v) {
+				return errs, true
+			}
+			continue
+		}
+		if t.Expr != nil {
+			if err := cfg.evalExpr(t.Expr, v, fc, rs.src); err != nil {
+				errs = append(errs, &FieldError{
+					Rule:    t.Expr.text,
+					Value:   v,
+					Err:     err,
+					Message: t.Message,
+					Code:    t.Code,
+				})
+			}
+			continue
+		}
+		if err := cfg.runTag(t, v, fc, rs.src); err != nil {
+			errs = append(errs, &FieldError{
+				Rule:    t.Name,
+				Param:   t.Param,
+				Value:   v,
+				Err:     err,
+				Message: t.Message,
+				Code:    t.Code,
+			})
+		}
+	}
+	return errs, false
+}
 
-func splitUnescapedComma(str string) []string {
-	ret := []string{}
-	indexes := sepPattern.FindAllStringIndex(str, -1)
-	last := 0
-	for _, is := range indexes {
-		ret = append(ret, str[last:is[1]-1])
-		last = is[1]
+// omitted reports whether omitempty stops the rules of the field of
+// struct sv described by fp, as its value is empty.
+func (fp *fieldPlan) omitted(sv reflect.Value) bool {
+	if fp.err != nil || fp.rules == nil {
+		return false
 	}
-	ret = append(ret, str[last:])
-	return ret
+	for i := range fp.rules.tags {
+		if fp.rules.tags[i].Name == omitEmptyTag {
+			return isEmpty(valueInterface(indirect(sv.Field(fp.index))))
+		}
+	}
+	return false
+}
+
+// isEmpty reports whether v is empty for omitempty: whether nonzero fails
+// on it or, for structs and the kinds nonzero does not support, whether it
+// is the zero value of its type.
+func isEmpty(v interface{}) bool {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Struct {
+		return rv.IsZero()
+	}
+	switch nonzero(v, "") {
+	case nil:
+		return false
+	case ErrUnsupported:
+		return reflect.ValueOf(v).IsZero()
+	}
+	return true
+}
+
+// runTag runs the rule t, parsed from the full tag src, against v.
+func (cfg *config) runTag(t *tag, v interface{}, fc fieldContext, src string) error {
+	var err error
+	if t.FieldFn != nil {
+		err = t.FieldFn(v, t.Param, fc)
+	} else {
+		err = t.Fn(v, t.Param)
+	}
+	if err != nil {
+		var te *TagError
+		if errors.Is(err, ErrBadParameter) && !errors.As(err, &te) {
+			err = &TagError{Tag: src, Rule: t.Name, Offset: t.ParamOffset, Err: err}
+		}
+	}
+	return err
+}
+
+// diveTag is the keyword of the tag grammar starting the rules
+// applied to the elements of a collection.
+const diveTag = tagsyntax.Dive
+
+// omitEmptyTag is the keyword of the tag grammar skipping the rules
+// following it for empty values.
+const omitEmptyTag = tagsyntax.OmitEmpty
+
+// ruleSet holds the parsed rules of a tag.
+type ruleSet struct {
+	// tags are the rules applied to the value itself.
+	tags []tag
+	// keys are the rules applied to the keys of a map.
+	keys *ruleSet
+	// elems are the rules applied to the elements of a
+	// slice or array, or to the values of a map.
+	elems *ruleSet
+	// src is the full tag the rules were parsed from.
+	src string
+	// dive is the byte offset in src of the dive keyword
+	// starting the rules of keys and elems.
+	dive int
+}
+
+// tag represents one of the tag items
+type tag struct {
+	Name        string         // name of the tag
+	Fn          ValidationFunc // validation function to call
+	FieldFn     fieldFunc      // field function to call instead of Fn, if set
+	Param       string         // parameter to send to the validation function
+	Offset      int            // byte offset of the name within the full tag
+	ParamOffset int            // byte offset of the parameter within the full tag
+	Expr        *tagExpr       // combination of rules, set with an empty Name
+	Message     string         // message template given with msg, if any
+	Code        string         // error code given with code, if any
 }
 
 // parseTags parses all individual tags found within a struct tag.
-func (mv *Validator) parseTags(t string) ([]tag, error) {
-	tl := splitUnescapedComma(t)
-	tags := make([]tag, 0, len(tl))
-	for _, i := range tl {
-		i = strings.Replace(i, `\,`, ",", -1)
-		tg := tag{}
-		v := strings.SplitN(i, "=", 2)
-		tg.Name = strings.Trim(v[0], " ")
-		if tg.Name == "" {
-			return []tag{}, ErrUnknownTag
-		}
-		if len(v) > 1 {
-			tg.Param = strings.Trim(v[1], " ")
+// Problems are reported as a *TagError.
+func (cfg *config) parseTags(t string) (*ruleSet, error) {
+	parsed, err := tagsyntax.Parse(t)
+	if err != nil {
+		e := err.(*tagsyntax.Error)
+		return nil, unknownTag(t, e.Rule, e.Offset)
+	}
+	return cfg.resolveRuleSet(parsed, t)
+}
+
+// resolveRuleSet looks up the functions of the rules of parsed, which
+// were parsed from the full tag src.
+func (cfg *config) resolveRuleSet(parsed *tagsyntax.RuleSet, src string) (*ruleSet, error) {
+	rs := &ruleSet{tags: make([]tag, 0, len(parsed.Rules)), src: src, dive: parsed.Dive}
+	for _, r := range parsed.Rules {
+		tg := tag{Name: r.Name, Param: r.Param, Offset: r.Offset, ParamOffset: r.ParamOffset, Message: r.Message, Code: r.Code}
+		if tg.Message == "" {
+			tg.Message = parsed.Message
+		}
+		if tg.Code == "" {
+			tg.Code = parsed.Code
+		}
+		if r.Name == omitEmptyTag {
+			if r.Param != "" {
+				return nil, &TagError{Tag: src, Rule: r.Name, Offset: r.ParamOffset, Err: ErrBadParameter}
+			}
+			rs.tags = append(rs.tags, tg)
+			continue
+		}
+		if r.Expr != nil {
+			var err error
+			if tg.Expr, err = cfg.resolveExpr(r.Expr, src); err != nil {
+				return nil, err
+			}
+			rs.tags = append(rs.tags, tg)
+			continue
 		}
 		var found bool
-		if tg.Fn, found = mv.validationFuncs[tg.Name]; !found {
-			return []tag{}, ErrUnknownTag
+		if tg.FieldFn, found = cfg.fieldFuncs[tg.Name]; !found {
+			if tg.Fn, found = cfg.validationFuncs[tg.Name]; !found {
+				return nil, unknownTag(src, tg.Name, tg.Offset)
+			}
+		}
+		rs.tags = append(rs.tags, tg)
+	}
+	var err error
+	if parsed.Keys != nil {
+		if rs.keys, err = cfg.resolveRuleSet(parsed.Keys, src); err != nil {
+			return nil, err
+		}
+	}
+	if parsed.Elems != nil {
+		if rs.elems, err = cfg.resolveRuleSet(parsed.Elems, src); err != nil {
+			return nil, err
 		}
-		tags = append(tags, tg)
+	}
+	return rs, nil
+}
+
+// unknownTag returns the error for a rule that cannot be used
+// at offset of the full tag src.
+func unknownTag(src, rule string, offset int) *TagError {
+	return &TagError{Tag: src, Rule: rule, Offset: offset, Err: ErrUnknownTag}
+}
 
+// fieldLabel returns the label of fieldDef, or "" if it has none.
+func (cfg *config) fieldLabel(fieldDef reflect.StructField) string {
+	if cfg.labelFunc != nil {
+		return cfg.labelFunc(fieldDef)
 	}
-	return tags, nil
+	return fieldDef.Tag.Get("label")
+}
+
+// jsonName returns the name given to the field by its json tag, or
+// the Go field name if there is none.
+func jsonName(fieldDef reflect.StructField) string {
+	if name := JSONFieldName(fieldDef); name != "" {
+		return name
+	}
+	return fieldDef.Name
 }
 
+// parseName returns the name given by the value of a tag written as
+// json tags are, or "" if it gives none or is "-".
 func parseName(tag string) string {
 	if tag == "" {
 		return ""
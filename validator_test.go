@@ -17,11 +17,16 @@
 package validator_test
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	. "gopkg.in/check.v1"
 	"gopkg.in/validator.v2"
@@ -490,6 +495,220 @@ func (ms *MySuite) TestBadParameter(c *C) {
 	c.Assert(errs["C"], HasError, validator.ErrBadParameter)
 }
 
+func (ms *MySuite) TestTagError(c *C) {
+	type test struct {
+		A string `validate:"nonzero, minn=3"`
+		B string `validate:"min=2,max= foo"`
+		C []int  `validate:"dive,keys,min=1"`
+		D int    `validate:"nonzero, ,min=1"`
+	}
+	// the errors wrapped are reported unless FieldErrors are asked for
+	err := validator.Validate(test{B: "abc"})
+	c.Assert(err, DeepEquals, validator.ErrorMap{
+		"A": {validator.ErrUnknownTag},
+		"B": {validator.ErrBadParameter},
+		"C": {validator.ErrUnknownTag},
+		"D": {validator.ErrUnknownTag},
+	})
+	c.Assert(validator.Valid("a", "min=x"), DeepEquals, validator.ErrorArray{validator.ErrBadParameter})
+	c.Assert(validator.Valid("a", "minn"), Equals, validator.ErrUnknownTag)
+
+	v := validator.WithFieldErrors(true)
+	err = v.Validate(test{B: "abc"})
+	c.Assert(err, NotNil)
+	errs, ok := err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 4)
+
+	var te *validator.TagError
+	c.Assert(errors.As(errs["A"][0], &te), Equals, true)
+	c.Assert(errors.Is(te, validator.ErrUnknownTag), Equals, true)
+	c.Assert(te.Type, Equals, reflect.TypeOf(test{}))
+	c.Assert(te.Field, Equals, "A")
+	c.Assert(te.Tag, Equals, "nonzero, minn=3")
+	c.Assert(te.Rule, Equals, "minn")
+	c.Assert(te.Offset, Equals, 9)
+	c.Assert(te.Error(), Equals, `unknown tag: rule "minn" at offset 9 of tag "nonzero, minn=3" on field validator_test.test.A`)
+
+	c.Assert(errors.As(errs["B"][0], &te), Equals, true)
+	c.Assert(errors.Is(errs["B"][0], validator.ErrBadParameter), Equals, true)
+	c.Assert(te.Field, Equals, "B")
+	c.Assert(te.Rule, Equals, "max")
+	c.Assert(te.Offset, Equals, 11)
+
+	c.Assert(errors.As(errs["C"][0], &te), Equals, true)
+	c.Assert(te.Rule, Equals, "endkeys")
+	c.Assert(te.Offset, Equals, 15)
+
+	c.Assert(errors.As(errs["D"][0], &te), Equals, true)
+	c.Assert(te.Rule, Equals, "")
+	c.Assert(te.Offset, Equals, 9)
+
+	err = v.Valid("a", "min=x")
+	c.Assert(err, WrapsError, validator.ErrBadParameter)
+	c.Assert(errors.As(err.(validator.ErrorArray)[0], &te), Equals, true)
+	c.Assert(te.Type, IsNil)
+	c.Assert(te.Offset, Equals, 4)
+}
+
+type checkedAddress struct {
+	Zip string `validate:"len=abc"`
+}
+
+type checkedEmbedded struct {
+	Code int `validate:"regexp=^[0-9]+$"`
+}
+
+type checkedUser struct {
+	checkedEmbedded
+	Name      string                     `validate:"nonzero,minn=2"`
+	Email     string                     `validate:"regexp=([a-z]"`
+	Age       int                        `validate:"min=18,max=99"`
+	Addresses []*checkedAddress          `validate:"min=1"`
+	Labels    map[string]checkedAddress  `validate:"dive,keys,max=x,endkeys,nonzero"`
+	Tags      []string                   `validate:"dive,min=1"`
+	Count     int                        `validate:"dive,min=1"`
+	Start     time.Time                  `validate:"ltfield=End"`
+	End       time.Time                  `validate:"gtfield=Name"`
+	Kind      string                     `validate:"required_if=Missing x"`
+	Parent    *checkedUser               `validate:"nonnil"`
+	Extra     map[string]*checkedAddress `validate:"max=3"`
+}
+
+func (ms *MySuite) TestCheck(c *C) {
+	err := validator.Check((*checkedUser)(nil))
+	c.Assert(err, NotNil)
+	errs, ok := err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 8)
+	c.Assert(errs["checkedEmbedded.Code"], WrapsError, validator.ErrUnsupported)
+	c.Assert(errs["Name"], WrapsError, validator.ErrUnknownTag)
+	c.Assert(errs["Email"], WrapsError, validator.ErrBadParameter)
+	c.Assert(errs["Addresses[].Zip"], WrapsError, validator.ErrBadParameter)
+	c.Assert(errs["Labels"], WrapsError, validator.ErrBadParameter)
+	// checkedAddress is reported once, under the first path found
+	c.Assert(errs["Labels[](value).Zip"], IsNil)
+	c.Assert(errs["Count"], WrapsError, validator.ErrUnsupported)
+	c.Assert(errs["End"], WrapsError, validator.ErrUnsupported)
+	c.Assert(errs["Kind"], WrapsError, validator.ErrBadParameter)
+
+	var te *validator.TagError
+	c.Assert(errors.As(errs["Email"][0], &te), Equals, true)
+	c.Assert(te.Type, Equals, reflect.TypeOf(checkedUser{}))
+	c.Assert(te.Field, Equals, "Email")
+	c.Assert(te.Rule, Equals, "regexp")
+	c.Assert(te.Offset, Equals, 7)
+	c.Assert(errors.As(errs["Count"][0], &te), Equals, true)
+	c.Assert(te.Rule, Equals, "dive")
+	c.Assert(errors.As(errs["Addresses[].Zip"][0], &te), Equals, true)
+	c.Assert(te.Type, Equals, reflect.TypeOf(checkedAddress{}))
+
+	// the same type given as reflect.Type
+	c.Assert(validator.Check(reflect.TypeOf(checkedUser{})), DeepEquals, err)
+
+	type valid struct {
+		A     int            `validate:"min=1,max=10"`
+		B     *string        `validate:"nonzero,regexp=^[a-z]*$"`
+		C     []float64      `validate:"min=1,dive,max=1.5"`
+		D     interface{}    `validate:"min=abc"`
+		E     map[string]int `validate:"dive,keys,min=1,endkeys,max=2"`
+		F     int            `validate:"gtefield=A"`
+		Inner struct {
+			G string `validate:"required_with=A Inner.G"`
+		}
+	}
+	c.Assert(validator.Check(valid{}), IsNil)
+	c.Assert(func() { validator.MustCheck(valid{}) }, Not(PanicMatches), ".*")
+	c.Assert(func() { validator.MustCheck(checkedAddress{}) }, PanicMatches, "validator: Zip: bad parameter.*")
+
+	// rules of custom functions are only known by name
+	v := validator.NewValidator()
+	v.SetValidationFunc("min", func(interface{}, string) error { return nil })
+	c.Assert(v.Check(struct {
+		A bool `validate:"min=x"`
+	}{}), IsNil)
+}
+
+func (ms *MySuite) TestConflicts(c *C) {
+	type test struct {
+		A int            `validate:"max=0,min=1"`
+		B string         `validate:"len=10,regexp=^$"`
+		C string         `validate:"max=0,nonzero"`
+		D int            `validate:"min=0,max=0,nonzero"`
+		E [3]int         `validate:"len=5"`
+		F []string       `validate:"min=1,max=4,len=5"`
+		G string         `validate:"min=4,regexp=^(a|bb)?$"`
+		H map[string]int `validate:"dive,min=3,max=2"`
+		I *uint          `validate:"min=5,max=3"`
+		J float64        `validate:"min=1.5,max=1.25,min=x"`
+
+		// satisfiable
+		K string   `validate:"nonzero,len=2,regexp=^[a-z]+$"`
+		L string   `validate:"max=3,regexp=abc"`
+		M int      `validate:"min=-1,max=1,nonzero"`
+		N [2]int   `validate:"len=2,nonzero"`
+		O string   `validate:"min=5,regexp=^a|b$"`
+		P []string `validate:"max=1,dive,min=3"`
+	}
+	err := validator.Conflicts(test{})
+	c.Assert(err, NotNil)
+	errs, ok := err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 10)
+	for _, f := range []string{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"} {
+		c.Assert(errs[f], WrapsError, validator.ErrConflict, Commentf("field %s", f))
+		c.Assert(errs[f], HasLen, 1)
+	}
+
+	var te *validator.TagError
+	c.Assert(errors.As(errs["A"][0], &te), Equals, true)
+	c.Assert(te.Field, Equals, "A")
+	c.Assert(te.Rule, Equals, "min")
+	c.Assert(te.Offset, Equals, 6)
+	c.Assert(te.Err, ErrorMatches, "conflicting rules: max=0 and min=1")
+	c.Assert(errors.As(errs["B"][0], &te), Equals, true)
+	c.Assert(te.Err, ErrorMatches, `conflicting rules: len=10 and regexp=\^\$`)
+	c.Assert(errors.As(errs["D"][0], &te), Equals, true)
+	c.Assert(te.Err, ErrorMatches, "conflicting rules: max=0 and nonzero")
+	c.Assert(errors.As(errs["E"][0], &te), Equals, true)
+	c.Assert(te.Err, ErrorMatches, `conflicting rules: type \[3\]int and len=5`)
+
+	// Check reports them with the other mistakes
+	err = validator.Check(test{})
+	c.Assert(err, NotNil)
+	errs, ok = err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 10)
+	c.Assert(errs["J"], HasLen, 2)
+	c.Assert(errs["J"], WrapsError, validator.ErrBadParameter)
+
+	// rules replaced by the user are not taken into account
+	v := validator.NewValidator()
+	v.SetValidationFunc("max", func(interface{}, string) error { return nil })
+	c.Assert(v.Conflicts(struct {
+		A int `validate:"max=0,min=1"`
+	}{}), IsNil)
+
+	// the empty value satisfies the rules following omitempty
+	type omitted struct {
+		A int    `validate:"omitempty,min=5,max=3"`
+		B int    `validate:"omitempty,nonzero"`
+		C string `validate:"max=3,omitempty,min=5"`
+		D int    `validate:"nonzero,omitempty,min=5,max=3"`
+		E string `validate:"min=1,omitempty,max=0"`
+		F []int  `validate:"max=4,omitempty,min=2,max=1"`
+	}
+	err = validator.Conflicts(omitted{})
+	c.Assert(err, NotNil)
+	errs, ok = err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 2)
+	c.Assert(errors.As(errs["D"][0], &te), Equals, true)
+	c.Assert(te.Err, ErrorMatches, "conflicting rules: min=5 and max=3")
+	c.Assert(errors.As(errs["E"][0], &te), Equals, true)
+	c.Assert(te.Err, ErrorMatches, "conflicting rules: min=1 and max=0")
+}
+
 func (ms *MySuite) TestCopy(c *C) {
 	v := validator.NewValidator()
 	// WithTag calls copy, so we just copy the validator with the same tag
@@ -511,6 +730,25 @@ func (ms *MySuite) TestCopy(c *C) {
 	c.Assert(errs["A"], HasError, validator.ErrUnknownTag)
 }
 
+func (ms *MySuite) TestReservedNames(c *C) {
+	v := validator.NewValidator()
+	called := false
+	f := func(interface{}, string) error {
+		called = true
+		return nil
+	}
+	for _, name := range []string{"dive", "keys", "endkeys", "omitempty", "msg", "code"} {
+		c.Check(v.SetValidationFunc(name, f), NotNil, Commentf("name %s", name))
+		c.Check(v.SetValidationFuncContext(name, func(context.Context, interface{}, string) error {
+			called = true
+			return nil
+		}), NotNil, Commentf("name %s", name))
+	}
+	c.Assert(v.SetValidationFunc("", f), NotNil)
+	c.Assert(v.Valid("a", "code=E1,nonzero"), IsNil)
+	c.Assert(called, Equals, false)
+}
+
 func (ms *MySuite) TestTagEscape(c *C) {
 	type test struct {
 		A string `validate:"min=0,regexp=^a{3\\,10}"`
@@ -844,35 +1082,1949 @@ func (ms *MySuite) TestTypeAliases(c *C) {
 	c.Assert(errs["B2"], HasError, validator.ErrMax)
 }
 
-type hasErrorChecker struct {
-	*CheckerInfo
+func (ms *MySuite) TestCachedPlanInvalidation(c *C) {
+	type test struct {
+		A string `validate:"custom" json:"a"`
+	}
+	v := validator.NewValidator()
+	err := v.Validate(test{})
+	c.Assert(err, NotNil)
+	errs, ok := err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs["A"], HasError, validator.ErrUnknownTag)
+
+	// registering the rule must be seen by the cached plan
+	v.SetValidationFunc("custom", func(_ interface{}, _ string) error { return validator.ErrInvalid })
+	err = v.Validate(test{})
+	c.Assert(err, NotNil)
+	errs, ok = err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs["A"], HasError, validator.ErrInvalid)
+
+	v.SetPrintJSON(true)
+	err = v.Validate(test{})
+	c.Assert(err, NotNil)
+	errs, ok = err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs["a"], HasError, validator.ErrInvalid)
+
+	v.SetTag("other")
+	err = v.Validate(test{})
+	c.Assert(err, IsNil)
 }
 
-func (c *hasErrorChecker) Check(params []interface{}, names []string) (bool, string) {
-	var (
-		ok    bool
-		slice []error
-		value error
-	)
-	slice, ok = params[0].(validator.ErrorArray)
-	if !ok {
-		return false, "First parameter is not an Errorarray"
+func (ms *MySuite) TestFieldError(c *C) {
+	type sub struct {
+		Age int `validate:"min=18" json:"age"`
 	}
-	value, ok = params[1].(error)
-	if !ok {
-		return false, "Second parameter is not an error"
+	type test struct {
+		Name  string `validate:"nonzero,max=3" json:"name,omitempty"`
+		Inner sub    `json:"inner"`
 	}
+	// the errors of the rules are reported unless FieldErrors are asked for
+	err := validator.WithPrintJSON(true).Validate(test{Name: "abcd", Inner: sub{Age: 12}})
+	c.Assert(err, DeepEquals, validator.ErrorMap{
+		"name":      {validator.ErrMax},
+		"inner.age": {validator.ErrMin},
+	})
 
-	for _, v := range slice {
-		if v == value {
-			return true, ""
+	v := validator.WithFieldErrors(true)
+	err = v.WithPrintJSON(true).Validate(test{Name: "abcd", Inner: sub{Age: 12}})
+	c.Assert(err, NotNil)
+	errs, ok := err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 2)
+
+	c.Assert(errs["name"], HasLen, 1)
+	var fe *validator.FieldError
+	c.Assert(errors.As(errs["name"][0], &fe), Equals, true)
+	c.Assert(fe.Path, Equals, "name")
+	c.Assert(fe.Field, Equals, "Name")
+	c.Assert(fe.JSONName, Equals, "name")
+	c.Assert(fe.Rule, Equals, "max")
+	c.Assert(fe.Param, Equals, "3")
+	c.Assert(fe.Value, Equals, "abcd")
+	c.Assert(errors.Is(fe, validator.ErrMax), Equals, true)
+	c.Assert(fe.Error(), Equals, validator.ErrMax.Error())
+
+	c.Assert(errs["inner.age"], HasLen, 1)
+	c.Assert(errors.As(errs["inner.age"][0], &fe), Equals, true)
+	c.Assert(fe.Path, Equals, "inner.age")
+	c.Assert(fe.Field, Equals, "Age")
+	c.Assert(fe.Rule, Equals, "min")
+	c.Assert(fe.Param, Equals, "18")
+	c.Assert(fe.Value, Equals, 12)
+	c.Assert(fe.Err, Equals, validator.ErrMin)
+
+	c.Assert(validator.Valid(5, "min=10"), DeepEquals, validator.ErrorArray{validator.ErrMin})
+	err = v.Valid(5, "min=10")
+	errArr, ok := err.(validator.ErrorArray)
+	c.Assert(ok, Equals, true)
+	c.Assert(errors.As(errArr[0], &fe), Equals, true)
+	c.Assert(fe.Path, Equals, "")
+	c.Assert(fe.Rule, Equals, "min")
+	c.Assert(fe.Value, Equals, 5)
+}
+
+func (ms *MySuite) TestCrossField(c *C) {
+	type period struct {
+		Start time.Time
+		End   time.Time `validate:"gtfield=Start"`
+	}
+	type test struct {
+		Password        string `validate:"min=3"`
+		PasswordConfirm string `validate:"eqfield=Password"`
+		Username        string `validate:"nefield=Password"`
+		Min             int
+		Max             int     `validate:"gtefield=Min"`
+		Limit           uint    `validate:"ltfield=Cap"`
+		Cap             uint    `validate:"ltefield=Period.Count"`
+		Ratio           float64 `validate:"ltfield=Min"`
+		Period          struct {
+			period
+			Count uint
 		}
+		Deadline *time.Time `validate:"ltefield=Period.End"`
 	}
-	return false, ""
+	now := time.Now()
+	t := test{
+		Password:        "secret",
+		PasswordConfirm: "secret",
+		Username:        "joe",
+		Min:             1,
+		Max:             1,
+		Limit:           2,
+		Cap:             3,
+		Ratio:           0.5,
+	}
+	t.Period.Start = now
+	t.Period.End = now.Add(time.Hour)
+	t.Period.Count = 3
+	t.Deadline = &t.Period.End
+	err := validator.Validate(t)
+	c.Assert(err, NotNil)
+	errs, ok := err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	// float against int is not supported
+	c.Assert(errs, HasLen, 1)
+	c.Assert(errs["Ratio"], HasError, validator.ErrUnsupported)
+
+	late := now.Add(2 * time.Hour)
+	t.PasswordConfirm = "other"
+	t.Username = "secret"
+	t.Max = 0
+	t.Limit = 4
+	t.Cap = 4
+	t.Period.End = now
+	t.Deadline = &late
+	err = validator.Validate(&t)
+	c.Assert(err, NotNil)
+	errs, ok = err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 8)
+	c.Assert(errs["PasswordConfirm"], HasError, validator.ErrEqField)
+	c.Assert(errs["Username"], HasError, validator.ErrNeField)
+	c.Assert(errs["Max"], HasError, validator.ErrGteField)
+	c.Assert(errs["Limit"], HasError, validator.ErrLtField)
+	c.Assert(errs["Cap"], HasError, validator.ErrLteField)
+	c.Assert(errs["Period.period.End"], HasError, validator.ErrGtField)
+	c.Assert(errs["Deadline"], HasError, validator.ErrLteField)
+
+	// nil pointers are valid, unknown fields are not
+	type test2 struct {
+		A *int `validate:"eqfield=B"`
+		B int
+		C int `validate:"eqfield=Missing"`
+	}
+	err = validator.Validate(test2{})
+	c.Assert(err, NotNil)
+	errs, ok = err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 1)
+	c.Assert(errs["C"], HasError, validator.ErrBadParameter)
+
+	err = validator.Valid(1, "eqfield=A")
+	c.Assert(err, NotNil)
 }
 
-func (c *hasErrorChecker) Info() *CheckerInfo {
-	return c.CheckerInfo
+func (ms *MySuite) TestConditionalRequired(c *C) {
+	type address struct {
+		Street string
+		City   string `validate:"required_with=Street"`
+	}
+	type test struct {
+		AccountType string
+		Active      bool
+		Company     string  `validate:"required_if=AccountType business"`
+		VATNumber   *string `validate:"required_if=AccountType business Active true"`
+		Nickname    string  `validate:"required_unless=AccountType business"`
+		Email       string
+		Phone       string `validate:"required_without=Email"`
+		Address     address
+		Country     string `validate:"required_with=Address.City Address.Street"`
+	}
+
+	err := validator.Validate(test{AccountType: "business", Nickname: "x", Email: "a@b.c"})
+	c.Assert(err, NotNil)
+	errs, ok := err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 1)
+	c.Assert(errs["Company"], HasError, validator.ErrRequiredIf)
+
+	err = validator.Validate(test{AccountType: "business", Active: true, Company: "ACME", Address: address{Street: "Main"}})
+	c.Assert(err, NotNil)
+	errs, ok = err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 4)
+	c.Assert(errs["VATNumber"], HasError, validator.ErrRequiredIf)
+	c.Assert(errs["Phone"], HasError, validator.ErrRequiredWithout)
+	c.Assert(errs["Address.City"], HasError, validator.ErrRequiredWith)
+	c.Assert(errs["Country"], HasError, validator.ErrRequiredWith)
+
+	err = validator.Validate(test{AccountType: "personal", Phone: "123"})
+	c.Assert(err, NotNil)
+	errs, ok = err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 1)
+	c.Assert(errs["Nickname"], HasError, validator.ErrRequiredUnless)
+
+	type test2 struct {
+		A string `validate:"required_if=B"`
+		B string `validate:"required_with=Missing"`
+	}
+	err = validator.Validate(test2{})
+	c.Assert(err, NotNil)
+	errs, ok = err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs["A"], HasError, validator.ErrBadParameter)
+	c.Assert(errs["B"], HasError, validator.ErrBadParameter)
 }
 
-var HasError = &hasErrorChecker{&CheckerInfo{Name: "HasError", Params: []string{"HasError", "expected to contain"}}}
+func (ms *MySuite) TestDive(c *C) {
+	type item struct {
+		Name string `validate:"nonzero"`
+	}
+	type test struct {
+		Tags   []string          `validate:"max=3,dive,min=3,max=5"`
+		Labels map[string]string `validate:"dive,keys,min=2,endkeys,nonzero"`
+		Keys   map[string]int    `validate:"dive,keys,len=1,endkeys"`
+		Matrix [][]int           `validate:"len=1,dive,len=2,dive,min=1"`
+		Ptrs   []*int            `validate:"dive,nonzero"`
+		Items  []item            `validate:"dive,nonnil"`
+		Arr    [2]string         `validate:"dive,nonzero"`
+	}
+	zero := 0
+	t := test{
+		Tags:   []string{"abc", "ab", "abcdef", "abcd"},
+		Labels: map[string]string{"env": "", "x": "y"},
+		Keys:   map[string]int{"a": 1, "bb": 2},
+		Matrix: [][]int{{1, 0, 2}},
+		Ptrs:   []*int{nil, &zero},
+		Items:  []item{{}},
+		Arr:    [2]string{"a"},
+	}
+	err := validator.Validate(t)
+	c.Assert(err, NotNil)
+	errs, ok := err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 12)
+	c.Assert(errs["Tags"], HasError, validator.ErrMax)
+	c.Assert(errs["Tags[0]"], IsNil)
+	c.Assert(errs["Tags[1]"], HasError, validator.ErrMin)
+	c.Assert(errs["Tags[2]"], HasError, validator.ErrMax)
+	c.Assert(errs["Labels[env](value)"], HasError, validator.ErrZeroValue)
+	c.Assert(errs["Labels[x](key)"], HasError, validator.ErrMin)
+	c.Assert(errs["Labels[x](value)"], IsNil)
+	c.Assert(errs["Keys[bb](key)"], HasError, validator.ErrLen)
+	c.Assert(errs["Matrix[0]"], HasError, validator.ErrLen)
+	c.Assert(errs["Matrix[0][1]"], HasError, validator.ErrMin)
+	c.Assert(errs["Ptrs[0]"], HasError, validator.ErrZeroValue)
+	c.Assert(errs["Ptrs[1]"], HasError, validator.ErrZeroValue)
+	c.Assert(errs["Items[0].Name"], HasError, validator.ErrZeroValue)
+	c.Assert(errs["Arr[1]"], HasError, validator.ErrZeroValue)
+
+	v := validator.WithFieldErrors(true)
+	errs = v.Validate(t).(validator.ErrorMap)
+	var fe *validator.FieldError
+	c.Assert(errors.As(errs["Tags[1]"][0], &fe), Equals, true)
+	c.Assert(fe.Path, Equals, "Tags[1]")
+	c.Assert(fe.Field, Equals, "Tags")
+	c.Assert(fe.Value, Equals, "ab")
+
+	err = v.Valid([]string{"a", "bcd"}, "min=3,dive,len=3")
+	c.Assert(err, NotNil)
+	errArr, ok := err.(validator.ErrorArray)
+	c.Assert(ok, Equals, true)
+	c.Assert(errArr, HasLen, 2)
+	c.Assert(errors.Is(errArr[0], validator.ErrMin), Equals, true)
+	c.Assert(errors.As(errArr[1], &fe), Equals, true)
+	c.Assert(fe.Path, Equals, "[0]")
+	c.Assert(fe.Err, Equals, validator.ErrLen)
+
+	type test2 struct {
+		A string   `validate:"dive,min=1"`
+		B []string `validate:"keys,min=1"`
+		C []string `validate:"dive,keys,min=1"`
+		D []string `validate:"dive,endkeys"`
+	}
+	err = validator.Validate(test2{A: "abc", B: []string{""}})
+	c.Assert(err, NotNil)
+	errs, ok = err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 4)
+	c.Assert(errs["A"], HasError, validator.ErrUnsupported)
+	c.Assert(errs["B"], HasError, validator.ErrUnknownTag)
+	c.Assert(errs["C"], HasError, validator.ErrUnknownTag)
+	c.Assert(errs["D"], HasError, validator.ErrUnknownTag)
+}
+
+var errTooLarge = errors.New("too large")
+
+type selfChecked struct {
+	A int `validate:"min=1"`
+	B int
+}
+
+func (s selfChecked) Validate() error {
+	if s.B > 10 {
+		return errTooLarge
+	}
+	return nil
+}
+
+type ptrChecked struct {
+	Name string
+}
+
+func (p *ptrChecked) Validate() error {
+	if p.Name == "" {
+		return validator.ErrorMap{"Name": validator.ErrorArray{
+			&validator.FieldError{Path: "Name", Field: "Name", Err: validator.ErrZeroValue},
+		}}
+	}
+	return nil
+}
+
+type ctxChecked struct {
+	Min int `validate:"nonzero"`
+	Max int
+}
+
+func (r ctxChecked) ValidateWithContext(ctx context.Context, v *validator.Validator) error {
+	if err := v.Valid(r.Max, fmt.Sprintf("min=%d", r.Min)); err != nil {
+		return validator.ErrorMap{"Max": err.(validator.ErrorArray)}
+	}
+	return nil
+}
+
+type recursiveChecked struct {
+	A int `validate:"nonzero"`
+}
+
+func (r recursiveChecked) ValidateWithContext(ctx context.Context, v *validator.Validator) error {
+	return v.Validate(r)
+}
+
+type selfCheckedCode string
+
+func (c selfCheckedCode) Validate() error {
+	if len(c) != 2 {
+		return validator.ErrLen
+	}
+	return nil
+}
+
+type validatedUser struct {
+	Name string `validate:"nonzero"`
+}
+
+func (u validatedUser) Validate() error {
+	return validator.Validate(u)
+}
+
+func (ms *MySuite) TestValidatable(c *C) {
+	type test struct {
+		Self  selfChecked
+		Ptr   *ptrChecked
+		Value ptrChecked
+		Range ctxChecked
+		List  []selfChecked
+		Map   map[string]*ptrChecked
+		Code  selfCheckedCode
+		Codes []selfCheckedCode
+	}
+	t := test{
+		Self:  selfChecked{A: 0, B: 11},
+		Ptr:   &ptrChecked{},
+		Range: ctxChecked{Min: 0, Max: -1},
+		List:  []selfChecked{{A: 1}, {A: 1, B: 12}},
+		Map:   map[string]*ptrChecked{"a": {}, "b": {Name: "b"}},
+		Code:  "abc",
+		Codes: []selfCheckedCode{"ab", "c"},
+	}
+	v := validator.WithCallValidate(true)
+	err := v.Validate(&t)
+	c.Assert(err, NotNil)
+	errs, ok := err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 10)
+	c.Assert(errs["Self"], HasError, errTooLarge)
+	c.Assert(errs["Self.A"], HasError, validator.ErrMin)
+	c.Assert(errs["Ptr.Name"], WrapsError, validator.ErrZeroValue)
+	c.Assert(errs["Value.Name"], WrapsError, validator.ErrZeroValue)
+	c.Assert(errs["Range.Min"], HasError, validator.ErrZeroValue)
+	c.Assert(errs["Range.Max"], HasError, validator.ErrMin)
+	c.Assert(errs["List[1]"], HasError, errTooLarge)
+	c.Assert(errs["Map[a](value).Name"], WrapsError, validator.ErrZeroValue)
+	c.Assert(errs["Code"], HasError, validator.ErrLen)
+	c.Assert(errs["Codes[1]"], HasError, validator.ErrLen)
+	c.Assert(errs["List[0].A"], IsNil)
+
+	var fe *validator.FieldError
+	c.Assert(errors.As(errs["Ptr.Name"][0], &fe), Equals, true)
+	c.Assert(fe.Path, Equals, "Ptr.Name")
+
+	// the root value itself, with a pointer receiver called on a copy
+	err = v.Validate(ptrChecked{})
+	c.Assert(err, NotNil)
+	errs, ok = err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs["Name"], WrapsError, validator.ErrZeroValue)
+
+	err = v.Validate(selfChecked{A: 1, B: 11})
+	c.Assert(err, NotNil)
+	errs, ok = err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs[""], HasError, errTooLarge)
+
+	err = v.Validate(ctxChecked{Min: 1, Max: 2})
+	c.Assert(err, IsNil)
+
+	// validating the receiver again does not call its method forever
+	err = v.Validate(recursiveChecked{})
+	c.Assert(err, NotNil)
+	errs, ok = err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 1)
+	c.Assert(errs["A"], HasError, validator.ErrZeroValue)
+
+	// methods are not called unless asked for, so they can validate
+	// their receiver
+	c.Assert(validator.Validate(validatedUser{}), DeepEquals,
+		validator.ErrorMap{"Name": {validator.ErrZeroValue}})
+	c.Assert(validator.Validate(test{Self: selfChecked{A: 1, B: 11}, Range: ctxChecked{Min: 1}}), IsNil)
+
+	// the plans of a validator follow its setting
+	type codes struct {
+		Codes []selfCheckedCode
+	}
+	v = validator.NewValidator()
+	c.Assert(v.Validate(codes{Codes: []selfCheckedCode{"ab", "c"}}), IsNil)
+	v.SetCallValidate(true)
+	errs, ok = v.Validate(codes{Codes: []selfCheckedCode{"ab", "c"}}).(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs["Codes[1]"], HasError, validator.ErrLen)
+}
+
+type tenantKey struct{}
+
+func (ms *MySuite) TestValidateContext(c *C) {
+	v := validator.NewValidator()
+	v.SetValidationFuncContext("tenant", func(ctx context.Context, val interface{}, param string) error {
+		if ctx.Value(tenantKey{}) != val {
+			return validator.ErrInvalid
+		}
+		return nil
+	})
+	type test struct {
+		Tenant string `validate:"tenant"`
+	}
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+	c.Assert(v.ValidateContext(ctx, test{"acme"}), IsNil)
+	err := v.ValidateContext(ctx, test{"other"})
+	c.Assert(err, NotNil)
+	errs, ok := err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs["Tenant"], HasError, validator.ErrInvalid)
+
+	c.Assert(v.ValidContext(ctx, "acme", "tenant,min=2"), IsNil)
+	err = v.ValidContext(ctx, []string{"acme", "other"}, "dive,tenant")
+	c.Assert(err, NotNil)
+	errArr, ok := err.(validator.ErrorArray)
+	c.Assert(ok, Equals, true)
+	c.Assert(errArr, HasError, validator.ErrInvalid)
+
+	// without a context value, the rule sees an empty context
+	err = v.Validate(test{"acme"})
+	c.Assert(err, NotNil)
+
+	// a ValidationFunc of the same name replaces the context one
+	v.SetValidationFunc("tenant", func(val interface{}, param string) error { return nil })
+	c.Assert(v.Validate(test{"other"}), IsNil)
+	v.SetValidationFuncContext("tenant", nil)
+	err = v.Validate(test{"other"})
+	c.Assert(err, NotNil)
+	errs, ok = err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs["Tenant"], HasError, validator.ErrUnknownTag)
+}
+
+func (ms *MySuite) TestValidateContextCancel(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	v := validator.NewValidator()
+	v.SetValidationFuncContext("slow", func(ctx context.Context, val interface{}, param string) error {
+		calls++
+		if calls == 3 {
+			cancel()
+		}
+		return nil
+	})
+	type item struct {
+		A int `validate:"slow"`
+	}
+	type test struct {
+		Items []item
+	}
+	t := test{Items: make([]item, 100)}
+	err := v.ValidateContext(ctx, t)
+	c.Assert(err, Equals, context.Canceled)
+	c.Assert(calls, Equals, 3)
+
+	calls = 0
+	err = v.ValidContext(context.Background(), make([]int, 100), "dive,slow")
+	c.Assert(err, IsNil)
+	c.Assert(calls, Equals, 100)
+
+	// already cancelled
+	c.Assert(v.ValidateContext(ctx, t), Equals, context.Canceled)
+	c.Assert(v.ValidContext(ctx, 1, "min=0"), Equals, context.Canceled)
+	c.Assert(validator.ValidContext(ctx, 1, "min=0"), Equals, context.Canceled)
+	c.Assert(validator.ValidateContext(ctx, t), Equals, context.Canceled)
+}
+
+type cycleNode struct {
+	Name     string `validate:"nonzero"`
+	Parent   *cycleNode
+	Children []*cycleNode
+	Extra    map[string]interface{}
+}
+
+func (ms *MySuite) TestCycles(c *C) {
+	root := &cycleNode{Name: "root"}
+	child := &cycleNode{Parent: root}
+	root.Children = []*cycleNode{child, {Name: "b", Parent: root}}
+	root.Extra = map[string]interface{}{}
+	root.Extra["self"] = root.Extra
+
+	err := validator.Validate(root)
+	c.Assert(err, NotNil)
+	errs, ok := err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 1)
+	c.Assert(errs["Children[0].Name"], HasError, validator.ErrZeroValue)
+
+	err = validator.WithReportCycles(true).Validate(root)
+	c.Assert(err, NotNil)
+	errs, ok = err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 4)
+	c.Assert(errs["Children[0].Name"], HasError, validator.ErrZeroValue)
+	c.Assert(errs["Children[0].Parent"], HasError, validator.ErrCycle)
+	c.Assert(errs["Children[1].Parent"], HasError, validator.ErrCycle)
+	c.Assert(errs["Extra[self](value)"], HasError, validator.ErrCycle)
+
+	// the same pointer reached twice without a cycle is walked twice
+	shared := &cycleNode{}
+	err = validator.WithReportCycles(true).Validate(&cycleNode{Name: "a", Children: []*cycleNode{shared, shared}})
+	c.Assert(err, NotNil)
+	errs, ok = err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 2)
+	c.Assert(errs["Children[0].Name"], HasError, validator.ErrZeroValue)
+	c.Assert(errs["Children[1].Name"], HasError, validator.ErrZeroValue)
+}
+
+func (ms *MySuite) TestMaxDepth(c *C) {
+	type inner struct {
+		A int `validate:"nonzero"`
+	}
+	type middle struct {
+		Inner inner
+		B     int `validate:"nonzero"`
+	}
+	type outer struct {
+		Middle []middle
+		C      int `validate:"nonzero"`
+	}
+	t := outer{Middle: []middle{{}}}
+
+	err := validator.WithMaxDepth(2).Validate(t)
+	c.Assert(err, NotNil)
+	errs, ok := err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 3)
+	c.Assert(errs["C"], HasError, validator.ErrZeroValue)
+	c.Assert(errs["Middle[0].B"], HasError, validator.ErrZeroValue)
+	c.Assert(errs["Middle[0].Inner"], HasError, validator.ErrMaxDepth)
+
+	err = validator.WithMaxDepth(3).Validate(t)
+	c.Assert(err, NotNil)
+	errs, ok = err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 3)
+	c.Assert(errs["Middle[0].Inner.A"], HasError, validator.ErrZeroValue)
+
+	// unbounded self references stop at the maximum depth too
+	var n *cycleNode
+	for i := 0; i < 10; i++ {
+		n = &cycleNode{Name: "n", Children: []*cycleNode{n}}
+	}
+	err = validator.WithMaxDepth(5).Validate(n)
+	c.Assert(err, NotNil)
+	errs, ok = err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 1)
+	c.Assert(errs["Children[0].Children[0].Children[0].Children[0].Children[0]"], HasError, validator.ErrMaxDepth)
+}
+
+func (ms *MySuite) TestConcurrentConfiguration(c *C) {
+	type T struct {
+		A string `validate:"min=2,odd" other:"nonzero"`
+	}
+	errEven := errors.New("even length")
+	odd := func(v interface{}, param string) error {
+		if len(v.(string))%2 == 0 {
+			return errEven
+		}
+		return nil
+	}
+	v := validator.NewValidator()
+	c.Assert(v.SetValidationFunc("odd", odd), IsNil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if j%2 == 0 {
+					v.SetTag("other")
+				} else {
+					v.SetTag("validate")
+				}
+				v.SetValidationFunc("odd", odd)
+				v.SetPrintJSON(j%3 == 0)
+				v.WithMaxDepth(i).Validate(T{A: "abc"})
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				// every configuration seen is complete, so the only
+				// possible errors are those of the tags
+				if err := v.Validate(T{A: "ab"}); err != nil {
+					errs := err.(validator.ErrorMap)
+					c.Check(errs["A"], HasLen, 1)
+					c.Check(errs["A"], HasError, errEven)
+				}
+				c.Check(v.Validate(T{A: "abc"}), IsNil)
+			}
+		}()
+	}
+	wg.Wait()
+
+	v.SetTag("validate")
+	err := v.Validate(T{A: "ab"})
+	c.Assert(err, NotNil)
+	c.Assert(err.(validator.ErrorMap)["A"], HasError, errEven)
+}
+
+var errGenerated = errors.New("generated")
+
+// generatedUser stands for a type given a method by validatorgen.
+type generatedUser struct {
+	Name    string `validate:"nonzero"`
+	Address generatedAddress
+}
+
+func (u generatedUser) ValidateFields() error {
+	return validator.ErrorMap{"Name": {errGenerated}}
+}
+
+func (generatedUser) ValidatorGenerated(validator.Generated) {}
+
+// handWrittenUser has a ValidateFields method of its own.
+type handWrittenUser struct {
+	Name string `validate:"nonzero"`
+}
+
+func (u handWrittenUser) ValidateFields() error {
+	return validator.ErrorMap{"Name": {errGenerated}}
+}
+
+type generatedAddress struct {
+	Zip string `validate:"len=5"`
+}
+
+// embeddingUser has the method of generatedUser promoted.
+type embeddingUser struct {
+	generatedUser
+	Age int `validate:"min=18"`
+}
+
+func (ms *MySuite) TestFieldsValidator(c *C) {
+	u := generatedUser{Address: generatedAddress{Zip: "123"}}
+	err := validator.Validate(u)
+	c.Assert(err, NotNil)
+	errs := err.(validator.ErrorMap)
+	c.Assert(errs["Name"], HasLen, 1)
+	c.Assert(errs["Name"], HasError, errGenerated)
+	// nested structs are still walked
+	c.Assert(errs["Address.Zip"], HasError, validator.ErrLen)
+
+	// other validators and settings use the tags
+	for _, v := range []*validator.Validator{validator.NewValidator(), validator.WithTag("validate")} {
+		err = v.Validate(u)
+		c.Assert(err, NotNil)
+		c.Assert(err.(validator.ErrorMap)["Name"], HasError, validator.ErrZeroValue)
+	}
+	validator.SetPrintJSON(true)
+	err = validator.Validate(u)
+	validator.SetPrintJSON(false)
+	c.Assert(err, NotNil)
+	c.Assert(err.(validator.ErrorMap)["Name"], HasError, validator.ErrZeroValue)
+
+	// methods promoted from embedded fields are not used
+	err = validator.Validate(embeddingUser{generatedUser: u})
+	c.Assert(err, NotNil)
+	errs = err.(validator.ErrorMap)
+	c.Assert(errs["generatedUser.Name"], HasError, validator.ErrZeroValue)
+	c.Assert(errs["Age"], HasError, validator.ErrMin)
+
+	// methods not written by validatorgen do not replace the tags
+	err = validator.Validate(handWrittenUser{})
+	c.Assert(err, NotNil)
+	errs = err.(validator.ErrorMap)
+	c.Assert(errs["Name"], HasLen, 1)
+	c.Assert(errs["Name"], HasError, validator.ErrZeroValue)
+
+	// ValidateField checks the tag of a single field
+	err = validator.ValidateField(u, "Name")
+	c.Assert(err, NotNil)
+	errs = err.(validator.ErrorMap)
+	c.Assert(errs, HasLen, 1)
+	c.Assert(errs["Name"], HasError, validator.ErrZeroValue)
+	c.Assert(validator.ValidateField(u, "Address"), IsNil)
+	c.Assert(validator.ValidateField(u, "Missing"), IsNil)
+}
+
+type schemaAddress struct {
+	Street string `json:"street" validate:"nonzero"`
+	Zip    string `json:"zip,omitempty" validate:"len=5,regexp=^[0-9]+$"`
+}
+
+type schemaBase struct {
+	ID int64 `json:"id" validate:"min=1"`
+}
+
+type schemaUser struct {
+	schemaBase
+	Name    string         `json:"name" validate:"nonzero,max=40"`
+	Age     *int           `json:"age" validate:"min=18,max=130"`
+	Score   float64        `validate:"min=0.5"`
+	Tags    []string       `json:"tags" validate:"max=3,dive,min=2"`
+	Attrs   map[string]int `json:"attrs" validate:"dive,keys,min=2,endkeys,max=9"`
+	Home    *schemaAddress `json:"home" validate:"nonnil"`
+	Work    *schemaAddress `json:"work"`
+	Born    time.Time      `json:"born"`
+	Even    int            `json:"even" validate:"even"`
+	Secret  string         `json:"-" validate:"nonzero"`
+	Confirm string         `json:"confirm" validate:"eqfield=Name"`
+	Friends []*schemaUser  `json:"friends"`
+	Data    []byte         `json:"data" validate:"nonzero"`
+}
+
+func (ms *MySuite) TestJSONSchema(c *C) {
+	v := validator.NewValidator()
+	v.SetValidationFunc("even", func(interface{}, string) error { return nil })
+	v.SetSchemaFunc("even", func(r *validator.SchemaRule) error {
+		r.Schema.Extra = map[string]interface{}{"multipleOf": 2}
+		return nil
+	})
+	s, err := v.JSONSchema(schemaUser{})
+	c.Assert(err, IsNil)
+	c.Assert(s.Schema, Equals, validator.SchemaDialect)
+	c.Assert(s.Ref, Equals, "#/$defs/schemaUser")
+	c.Assert(s.Defs, HasLen, 2)
+
+	b, err := json.Marshal(s.Defs["schemaAddress"])
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, `{"type":"object","properties":{`+
+		`"street":{"type":"string","minLength":1},`+
+		`"zip":{"type":"string","minLength":5,"maxLength":5,"pattern":"^[0-9]+$"}},`+
+		`"required":["street"]}`)
+
+	user := s.Defs["schemaUser"]
+	c.Assert(user.Required, DeepEquals, []string{"name", "home", "data"})
+	props := map[string]string{
+		// embedded structs are inlined
+		"id":    `{"type":"integer","minimum":1}`,
+		"name":  `{"type":"string","minLength":1,"maxLength":40}`,
+		"age":   `{"type":["integer","null"],"minimum":18,"maximum":130}`,
+		"Score": `{"type":"number","minimum":0.5}`,
+		"tags":  `{"type":["array","null"],"items":{"type":"string","minLength":2},"maxItems":3}`,
+		"attrs": `{"type":["object","null"],"additionalProperties":{"type":"integer","maximum":9},"propertyNames":{"type":"string","minLength":2}}`,
+		"home":  `{"$ref":"#/$defs/schemaAddress"}`,
+		"work":  `{"anyOf":[{"$ref":"#/$defs/schemaAddress"},{"type":"null"}]}`,
+		"born":  `{"type":"string","format":"date-time"}`,
+		"even":  `{"multipleOf":2,"type":"integer"}`,
+		// cross-field rules are left out
+		"confirm": `{"type":"string"}`,
+		"friends": `{"type":["array","null"],"items":{"anyOf":[{"$ref":"#/$defs/schemaUser"},{"type":"null"}]}}`,
+		"data":    `{"type":"string","contentEncoding":"base64","minLength":1}`,
+	}
+	c.Assert(user.Properties, HasLen, len(props))
+	for name, want := range props {
+		b, err := json.Marshal(user.Properties[name])
+		c.Assert(err, IsNil)
+		c.Check(string(b), Equals, want, Commentf("property %s", name))
+	}
+
+	// the default validator has no SchemaFunc for even
+	s, err = validator.JSONSchema(reflect.TypeOf(schemaAddress{}))
+	c.Assert(err, IsNil)
+	c.Assert(s.Defs["schemaAddress"].Properties, HasLen, 2)
+
+	type Bad struct {
+		Active bool `validate:"min=1"`
+	}
+	_, err = validator.JSONSchema(Bad{})
+	c.Assert(errors.Is(err, validator.ErrUnsupported), Equals, true)
+	var te *validator.TagError
+	c.Assert(errors.As(err, &te), Equals, true)
+	c.Assert(te.Field, Equals, "Active")
+	c.Assert(te.Rule, Equals, "min")
+
+	type BadParam struct {
+		Age int `validate:"max=old"`
+	}
+	_, err = validator.JSONSchema(&BadParam{})
+	c.Assert(errors.Is(err, validator.ErrBadParameter), Equals, true)
+
+	// replacing a builtin rule removes its SchemaFunc
+	v.SetValidationFunc("max", func(interface{}, string) error { return nil })
+	s, err = v.JSONSchema(BadParam{})
+	c.Assert(err, IsNil)
+	b, err = json.Marshal(s.Defs["BadParam"].Properties["Age"])
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, `{"type":"integer"}`)
+
+	// byte slices are bounded by the length of their base64 encoding
+	s, err = validator.JSONSchema(schemaBlob{})
+	c.Assert(err, IsNil)
+	b, err = json.Marshal(s.Defs["schemaBlob"].Properties)
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, `{`+
+		`"key":{"type":["string","null"],"contentEncoding":"base64","minLength":8,"maxLength":8},`+
+		`"note":{"type":["string","null"],"contentEncoding":"base64","maxLength":0},`+
+		`"salt":{"type":["string","null"],"contentEncoding":"base64","minLength":4,"maxLength":8}}`)
+	// lengths are told apart by multiples of 3 only
+	for n, valid := range map[int]bool{0: false, 1: true, 2: true, 4: true, 6: true, 7: false} {
+		doc, err := json.Marshal(schemaBlob{Key: make([]byte, 6), Salt: make([]byte, n)})
+		c.Assert(err, IsNil)
+		var blob interface{}
+		c.Assert(json.Unmarshal(doc, &blob), IsNil)
+		c.Check(validator.ValidateJSON(blob, s) == nil, Equals, valid, Commentf("%d bytes", n))
+	}
+}
+
+type schemaBlob struct {
+	Key  []byte `json:"key" validate:"len=6"`
+	Salt []byte `json:"salt" validate:"min=2,max=4"`
+	Note []byte `json:"note" validate:"max=0"`
+}
+
+type schemaCodes []string
+
+func (ms *MySuite) TestOpenAPI(c *C) {
+	v := validator.NewValidator()
+	v.SetValidationFunc("even", func(interface{}, string) error { return nil })
+	comps, err := v.OpenAPI(&schemaUser{}, reflect.TypeOf(schemaCodes{}))
+	c.Assert(err, IsNil)
+	c.Assert(comps.Schemas, HasLen, 3)
+	c.Assert(comps.Schemas["schemaCodes"].Type, DeepEquals, validator.SchemaType{"array"})
+
+	user := comps.Schemas["schemaUser"]
+	c.Assert(user.Schema, Equals, "")
+	c.Assert(user.Required, DeepEquals, []string{"name", "home", "data"})
+	// keywords are sorted once nullable is added
+	props := map[string]string{
+		"id":      `{"type":"integer","minimum":1}`,
+		"age":     `{"maximum":130,"minimum":18,"nullable":true,"type":"integer"}`,
+		"attrs":   `{"additionalProperties":{"type":"integer","maximum":9},"nullable":true,"type":"object"}`,
+		"home":    `{"$ref":"#/components/schemas/schemaAddress"}`,
+		"work":    `{"allOf":[{"$ref":"#/components/schemas/schemaAddress"}],"nullable":true}`,
+		"born":    `{"type":"string","format":"date-time"}`,
+		"friends": `{"items":{"allOf":[{"$ref":"#/components/schemas/schemaUser"}],"nullable":true},"nullable":true,"type":"array"}`,
+		"data":    `{"type":"string","format":"byte","minLength":1}`,
+	}
+	for name, want := range props {
+		b, err := json.Marshal(user.Properties[name])
+		c.Assert(err, IsNil)
+		c.Check(string(b), Equals, want, Commentf("property %s", name))
+	}
+
+	doc, err := comps.JSON()
+	c.Assert(err, IsNil)
+	var back struct {
+		Components struct {
+			Schemas map[string]*validator.Schema `json:"schemas"`
+		} `json:"components"`
+	}
+	c.Assert(json.Unmarshal(doc, &back), IsNil)
+	c.Assert(back.Components.Schemas, HasLen, 3)
+
+	comps, err = validator.OpenAPI(schemaAddress{})
+	c.Assert(err, IsNil)
+	doc, err = comps.YAML()
+	c.Assert(err, IsNil)
+	c.Assert(string(doc), Equals, `components:
+  schemas:
+    schemaAddress:
+      properties:
+        street:
+          minLength: 1
+          type: string
+        zip:
+          maxLength: 5
+          minLength: 5
+          pattern: "^[0-9]+$"
+          type: string
+      required:
+        - street
+      type: object
+`)
+
+	comps, err = validator.OpenAPI(schemaBlob{})
+	c.Assert(err, IsNil)
+	b, err := json.Marshal(comps.Schemas["schemaBlob"].Properties["salt"])
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, `{"format":"byte","maxLength":8,"minLength":4,"nullable":true,"type":"string"}`)
+
+	_, err = validator.OpenAPI([]schemaAddress{})
+	c.Assert(err, Equals, validator.ErrUnsupported)
+}
+
+type thirdAddress struct {
+	Street string
+	Zip    string
+}
+
+type thirdBase struct {
+	ID int
+}
+
+type thirdUser struct {
+	thirdBase
+	Name    string `validate:"max=5"`
+	Address *thirdAddress
+	Tags    []string `validate:"min=1"`
+	Phones  []thirdAddress
+	Note    string `validate:"nonzero"`
+	secret  string
+}
+
+func (ms *MySuite) TestRegisterRules(c *C) {
+	v := validator.NewValidator()
+	err := v.RegisterRules(thirdUser{}, map[string]string{
+		"Name":        "nonzero,max=10",
+		"Address.Zip": "len=5",
+		"ID":          "min=1",
+		"Note":        "-",
+	})
+	c.Assert(err, IsNil)
+	c.Assert(v.MergeRules(&thirdUser{}, map[string]string{"Tags": "dive,nonzero"}), IsNil)
+
+	u := thirdUser{
+		thirdBase: thirdBase{ID: 1},
+		Name:      "Gandalf",
+		Address:   &thirdAddress{Zip: "1"},
+		Tags:      []string{""},
+	}
+	errs, ok := v.Validate(u).(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 2)
+	c.Assert(errs["Address.Zip"], HasError, validator.ErrLen)
+	c.Assert(errs["Tags[0]"], HasError, validator.ErrZeroValue)
+
+	u.Name, u.ID, u.Tags, u.Address.Zip = "", 0, nil, "12345"
+	errs, ok = v.Validate(u).(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 3)
+	c.Assert(errs["Name"], HasError, validator.ErrZeroValue)
+	c.Assert(errs["thirdBase.ID"], HasError, validator.ErrMin)
+	c.Assert(errs["Tags"], HasError, validator.ErrMin)
+
+	// rules of nested structs apply wherever the struct is found in the type
+	errs, ok = v.Validate(thirdUser{thirdBase: thirdBase{ID: 1}, Name: "a", Tags: []string{"a"}, Phones: []thirdAddress{{Zip: "1"}}}).(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs["Phones[0].Zip"], HasError, validator.ErrLen)
+
+	// but not within other types holding the struct
+	type office struct {
+		Owner   thirdUser
+		Address thirdAddress
+		Base    thirdBase
+	}
+	o := office{Owner: u, Address: thirdAddress{Zip: "1"}}
+	o.Owner.Name, o.Owner.ID, o.Owner.Tags, o.Owner.Address = "a", 1, []string{"a"}, &thirdAddress{Zip: "1"}
+	errs, ok = v.Validate(o).(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 1)
+	c.Assert(errs["Owner.Address.Zip"], HasError, validator.ErrLen)
+	c.Assert(v.Validate(thirdAddress{Zip: "1"}), IsNil)
+	s, err := v.JSONSchema(office{})
+	c.Assert(err, IsNil)
+	// the struct is described once within the type, once outside of it
+	outside := s.Defs["office"].Properties["Address"].Ref
+	owner := s.Defs["thirdUser"].Properties["Phones"].Items.Ref
+	c.Assert(outside, Not(Equals), owner)
+	c.Assert(s.Defs[strings.TrimPrefix(outside, "#/$defs/")].Properties["Zip"].MinLength, IsNil)
+	c.Assert(*s.Defs[strings.TrimPrefix(owner, "#/$defs/")].Properties["Zip"].MinLength, Equals, int64(5))
+	c.Assert(s.Defs["thirdBase"].Properties["ID"].Minimum, Equals, json.Number(""))
+	checked := validator.NewValidator()
+	c.Assert(checked.RegisterRules(thirdUser{}, map[string]string{"Address.Zip": "min=x"}), IsNil)
+	c.Assert(checked.Check(thirdAddress{}), IsNil)
+	errs, ok = checked.Check(office{}).(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 1)
+	c.Assert(errs["Owner.Address.Zip"], WrapsError, validator.ErrBadParameter)
+
+	// the default validator is left alone
+	errs, ok = validator.Validate(thirdUser{Name: "Gandalf"}).(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs["Name"], HasError, validator.ErrMax)
+	c.Assert(errs["Note"], HasError, validator.ErrZeroValue)
+
+	for _, path := range []string{"Missing", "Address.Missing", "secret", "Name.Length", ""} {
+		err = v.RegisterRules(thirdUser{}, map[string]string{path: "nonzero"})
+		c.Check(errors.Is(err, validator.ErrUnknownField), Equals, true, Commentf("path %q", path))
+	}
+	c.Assert(v.RegisterRules(0, map[string]string{"ID": "min=1"}), Equals, validator.ErrUnsupported)
+
+	// merged rules are checked as if they followed the tag
+	c.Assert(v.MergeRules(thirdUser{}, map[string]string{"Name": "nope"}), IsNil)
+	errs, ok = v.Check(thirdUser{}).(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs["Name"], HasLen, 1)
+	te, ok := errs["Name"][0].(*validator.TagError)
+	c.Assert(ok, Equals, true)
+	c.Assert(te.Tag, Equals, "max=5,nope")
+	c.Assert(te.Rule, Equals, "nope")
+	c.Assert(te.Offset, Equals, 6)
+}
+
+func (ms *MySuite) TestLoadRules(c *C) {
+	want := map[string]map[string]string{
+		"thirdUser":    {"Name": "nonzero,max=10", "Note": "-"},
+		"thirdAddress": {"Zip": "len=5,regexp=^[0-9]+$", "Street": "nonzero"},
+	}
+	rules, err := validator.ReadRules(strings.NewReader(`{
+		"thirdUser": {"Name": "nonzero,max=10", "Note": "-"},
+		"thirdAddress": {"Zip": "len=5,regexp=^[0-9]+$", "Street": "nonzero"}
+	}`))
+	c.Assert(err, IsNil)
+	c.Assert(rules, DeepEquals, want)
+
+	yaml := `# rules of the partner API
+thirdUser:
+  Name: nonzero,max=10
+  Note: '-'
+
+validator_test.thirdAddress:
+  Zip: "len=5,regexp=^[0-9]+$"
+  Street: nonzero
+`
+	rules, err = validator.ReadRules(strings.NewReader(yaml))
+	c.Assert(err, IsNil)
+	c.Assert(rules["thirdUser"], DeepEquals, want["thirdUser"])
+	c.Assert(rules["validator_test.thirdAddress"], DeepEquals, want["thirdAddress"])
+
+	for _, bad := range []string{"  Name: nonzero\n", "thirdUser: nonzero\n", "thirdUser:\n  Name\n", "{\"thirdUser\": 1}"} {
+		_, err = validator.ReadRules(strings.NewReader(bad))
+		c.Check(err, NotNil, Commentf("rules %q", bad))
+	}
+
+	v := validator.NewValidator()
+	c.Assert(v.LoadRules(strings.NewReader(yaml), thirdUser{}, reflect.TypeOf(thirdAddress{})), IsNil)
+	errs, ok := v.Validate(thirdUser{Name: "Gandalf", Tags: []string{"a"}, Address: &thirdAddress{Zip: "abcde"}}).(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 2)
+	c.Assert(errs["Address.Zip"], HasError, validator.ErrRegexp)
+	c.Assert(errs["Address.Street"], HasError, validator.ErrZeroValue)
+
+	// nothing is registered when a type is missing
+	v = validator.NewValidator()
+	c.Assert(v.LoadRules(strings.NewReader(yaml), thirdUser{}), NotNil)
+	errs, ok = v.Validate(thirdUser{Name: "Gandalf", Note: "x", Tags: []string{"a"}}).(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs["Name"], HasError, validator.ErrMax)
+}
+
+type builtAddress struct {
+	Street string
+	Zip    string
+}
+
+type builtUser struct {
+	thirdBase
+	Name    string
+	Score   float64
+	Home    builtAddress
+	Work    *builtAddress
+	Tags    []string
+	Labels  map[string]string
+	Partner *builtUser
+}
+
+type taggedAddress struct {
+	Street string
+	Zip    string `validate:"len=5,regexp=^[0-9\\,]+$"`
+}
+
+type taggedUser struct {
+	Name    string  `validate:"nonzero,max=40"`
+	Score   float64 `validate:"min=0.5"`
+	Home    taggedAddress
+	Work    *taggedAddress    `validate:"nonnil"`
+	Tags    []string          `validate:"max=2,dive,nonzero"`
+	Labels  map[string]string `validate:"dive,keys,min=2,endkeys,nonzero"`
+	Partner *taggedUser
+}
+
+func (ms *MySuite) TestRulesBuilder(c *C) {
+	v := validator.NewValidator()
+	v.SetFieldErrors(true)
+	tr := validator.Rules[builtUser]()
+	validator.Field(tr, func(u *builtUser) *string { return &u.Name }, validator.NonZero(), validator.Max(40))
+	validator.Field(tr, func(u *builtUser) *float64 { return &u.Score }, validator.Min(0.5))
+	validator.Field(tr, func(u *builtUser) **builtAddress { return &u.Work }, validator.NonNil())
+	validator.Field(tr, func(u *builtUser) *string { return &u.Work.Zip }, validator.Len(5), validator.Regexp("^[0-9,]+$"))
+	validator.Field(tr, func(u *builtUser) *[]string { return &u.Tags }, validator.Dive(validator.NonZero()), validator.Max(2))
+	validator.Field(tr, func(u *builtUser) *map[string]string { return &u.Labels },
+		validator.DiveMap([]validator.Rule{validator.Min(2)}, validator.NonZero()))
+	err := tr.RegisterWith(v)
+	c.Assert(err, IsNil)
+
+	built := builtUser{
+		Score:   0.1,
+		Home:    builtAddress{Zip: "1"},
+		Tags:    []string{"a", "", "c"},
+		Labels:  map[string]string{"a": ""},
+		Partner: &builtUser{Name: "Bilbo", Score: 1, Work: &builtAddress{Zip: "12,34"}},
+	}
+	tagged := taggedUser{
+		Score:   0.1,
+		Home:    taggedAddress{Zip: "1"},
+		Tags:    []string{"a", "", "c"},
+		Labels:  map[string]string{"a": ""},
+		Partner: &taggedUser{Name: "Bilbo", Score: 1, Work: &taggedAddress{Zip: "12,34"}},
+	}
+	want, ok := validator.WithFieldErrors(true).Validate(tagged).(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(want["Home.Zip"], WrapsError, validator.ErrLen)
+	got, ok := v.Validate(built).(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(got, HasLen, len(want))
+	for path, errs := range want {
+		c.Assert(got[path], HasLen, len(errs), Commentf("path %s", path))
+		for i, err := range errs {
+			w, g := err.(*validator.FieldError), got[path][i].(*validator.FieldError)
+			c.Check([]string{g.Path, g.Rule, g.Param, g.Err.Error()}, DeepEquals,
+				[]string{w.Path, w.Rule, w.Param, w.Err.Error()})
+		}
+	}
+
+	// embedded fields are named by their path
+	tr = validator.Rules[builtUser]()
+	validator.Field(tr, func(u *builtUser) *int { return &u.ID }, validator.Min(1), validator.Named("even", ""))
+	validator.Field(tr, func(u *builtUser) *builtAddress { return &u.Home }, validator.NonZero())
+	validator.Field(tr, func(u *builtUser) *string { return &u.Home.Street }, validator.Regexp("a,b"))
+	tags, err := tr.Tags()
+	c.Assert(err, IsNil)
+	c.Assert(tags, DeepEquals, map[string]string{
+		"thirdBase.ID": "min=1,even",
+		"Home":         "nonzero",
+		"Home.Street":  `regexp=a\,b`,
+	})
+
+	_, err = validator.Field(validator.Rules[builtUser](), func(u *builtUser) *string { return new(string) }).Tags()
+	c.Assert(errors.Is(err, validator.ErrUnknownField), Equals, true)
+	_, err = validator.Field(validator.Rules[builtUser](), func(u *builtUser) *string { return &u.Partner.Partner.Name }).Tags()
+	c.Assert(errors.Is(err, validator.ErrUnknownField), Equals, true)
+	_, err = validator.Field[builtUser, string](validator.Rules[builtUser](), nil).Tags()
+	c.Assert(errors.Is(err, validator.ErrBadParameter), Equals, true)
+	_, err = validator.Field(validator.Rules[int](), func(i *int) *int { return i }).Tags()
+	c.Assert(errors.Is(err, validator.ErrBadParameter), Equals, true)
+	_, err = validator.Field(validator.Rules[builtUser](),
+		func(u *builtUser) *[]string { return &u.Tags }, validator.Dive(), validator.Dive()).Tags()
+	c.Assert(errors.Is(err, validator.ErrBadParameter), Equals, true)
+	c.Assert(validator.Rules[int]().Register(), NotNil)
+}
+
+type exprUser struct {
+	Zip   string `validate:"(len=0|regexp=^[0-9]{5}$)"`
+	Role  string `validate:"nonzero,!regexp=^(admin|root)$"`
+	Code  string `validate:"(nonzero,max=3)|len=10"`
+	Agent string `validate:"!(regexp=^bot\\|crawler$),(regexp=^(a|b)|len=0)"`
+}
+
+func (ms *MySuite) TestExpressions(c *C) {
+	valid := exprUser{Zip: "12345", Role: "user", Code: "abc", Agent: "a"}
+	c.Assert(validator.Validate(valid), IsNil)
+	valid.Zip, valid.Code, valid.Agent = "", "0123456789", ""
+	c.Assert(validator.Validate(valid), IsNil)
+
+	errs, ok := validator.WithFieldErrors(true).Validate(exprUser{Zip: "123", Role: "root", Code: "abcd", Agent: "bot|crawler"}).(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 4)
+
+	// alternatives report the errors of each of them
+	c.Assert(errs["Zip"], HasLen, 1)
+	fe, ok := errs["Zip"][0].(*validator.FieldError)
+	c.Assert(ok, Equals, true)
+	c.Assert(fe.Rule, Equals, "len=0|regexp=^[0-9]{5}$")
+	c.Assert(fe.Error(), Equals, "len=0: invalid length or regexp=^[0-9]{5}$: regular expression mismatch")
+	alts, ok := fe.Err.(validator.AlternativesError)
+	c.Assert(ok, Equals, true)
+	c.Assert(alts, HasLen, 2)
+	alt := alts[1].(*validator.FieldError)
+	c.Assert(alt.Path, Equals, "Zip")
+	c.Assert(alt.Field, Equals, "Zip")
+	c.Assert(alt.Rule, Equals, "regexp")
+	c.Assert(alt.Param, Equals, "^[0-9]{5}$")
+	c.Assert(errors.Is(errs["Zip"], validator.ErrLen), Equals, true)
+	c.Assert(errors.Is(errs["Zip"], validator.ErrRegexp), Equals, true)
+
+	c.Assert(errs["Role"], WrapsError, validator.ErrNot)
+	c.Assert(errs["Role"][0].(*validator.FieldError).Rule, Equals, "!regexp=^(admin|root)$")
+
+	alts = errs["Code"][0].(*validator.FieldError).Err.(validator.AlternativesError)
+	c.Assert(alts, HasLen, 2)
+	c.Assert(alts.Error(), Equals, "max=3: greater than max or len=10: invalid length")
+	c.Assert(validator.Valid("abc", "(len=2|len=5)"), ErrorMatches, "len=2: invalid length or len=5: invalid length")
+	c.Assert(validator.Valid("", "((nonzero,len=2)|len=5)"), ErrorMatches,
+		`\(nonzero: zero value, len=2: invalid length\) or len=5: invalid length`)
+	c.Assert(errors.Is(alts[0], validator.ErrMax), Equals, true)
+	c.Assert(errors.Is(alts[0], validator.ErrZeroValue), Equals, false)
+	c.Assert(errors.Is(alts[1], validator.ErrLen), Equals, true)
+
+	// \| is a | within the parameter
+	c.Assert(errs["Agent"], HasLen, 1)
+	c.Assert(errs["Agent"], WrapsError, validator.ErrNot)
+	c.Assert(validator.Valid("crawler", `!(regexp=^bot\|crawler$)`), HasError, validator.ErrNot)
+
+	// a | outside of an expression is part of the parameter
+	c.Assert(validator.Valid("b", "regexp=^a|b$"), IsNil)
+	c.Assert(validator.Valid("b", "(nonzero)"), IsNil)
+	c.Assert(validator.Valid("ab", "min=3|len=2"), HasError, validator.ErrBadParameter)
+	c.Assert(validator.Valid("ab", "(min=3|len=2)"), IsNil)
+	type bareAlternatives struct {
+		Code  string `validate:"nonzero,min=3|len=2"`
+		Regex string `validate:"regexp=^a|len=2$"`
+	}
+	errs = validator.Check(bareAlternatives{}).(validator.ErrorMap)
+	c.Assert(errs, HasLen, 1)
+	c.Assert(errs["Code"], WrapsError, validator.ErrBadParameter)
+	c.Assert(errs["Code"][0], ErrorMatches, `bad parameter, alternatives need parentheses, as in \(min=3\|len=2\): rule "min" at offset 12 .*`)
+
+	err := validator.Valid(true, "!min=1")
+	c.Assert(errors.Is(err, validator.ErrUnsupported), Equals, true)
+	err = validator.Valid("a", "(len=1|nope)")
+	c.Assert(errors.Is(err, validator.ErrUnknownTag), Equals, true)
+	for tag, rule := range map[string]string{
+		"(len=1|len=2":     "(",
+		"(len=1))":         ")",
+		"nonzero,(|len=1)": "",
+		"!(dive)":          "dive",
+	} {
+		var te *validator.TagError
+		c.Assert(errors.As(validator.WithFieldErrors(true).Valid("a", tag), &te), Equals, true, Commentf("tag %q", tag))
+		c.Check(te.Rule, Equals, rule, Commentf("tag %q", tag))
+		c.Check(te.Err, Equals, validator.ErrUnknownTag, Commentf("tag %q", tag))
+	}
+
+	type Bad struct {
+		A int    `validate:"(min=x|max=1)"`
+		B string `validate:"nonzero,!regexp=("`
+	}
+	errs, ok = validator.Check(Bad{}).(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs["A"], WrapsError, validator.ErrBadParameter)
+	c.Assert(errs["A"][0].(*validator.TagError).Offset, Equals, 5)
+	c.Assert(errs["B"], WrapsError, validator.ErrBadParameter)
+
+	s, err := validator.JSONSchema(exprUser{})
+	c.Assert(err, IsNil)
+	b, err := json.Marshal(s.Defs["exprUser"].Properties["Role"])
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, `{"type":"string","allOf":[{"not":{"pattern":"^(admin|root)$"}}],"minLength":1}`)
+	b, err = json.Marshal(s.Defs["exprUser"].Properties["Zip"])
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, `{"type":"string","allOf":[{"anyOf":[{"minLength":0,"maxLength":0},{"pattern":"^[0-9]{5}$"}]}]}`)
+	c.Assert(validator.ValidateJSON(map[string]interface{}{"Zip": "123", "Role": "admin"}, s), NotNil)
+}
+
+type omitUser struct {
+	Website string            `validate:"omitempty,regexp=^https://"`
+	Age     *int              `validate:"omitempty,min=18"`
+	Tags    []string          `validate:"omitempty,min=2,dive,min=3"`
+	Labels  map[string]string `validate:"omitempty,max=1"`
+	Count   int               `validate:"max=10,omitempty,min=5"`
+	Home    *schemaAddress    `validate:"omitempty,nonzero"`
+}
+
+func (ms *MySuite) TestOmitEmpty(c *C) {
+	c.Assert(validator.Validate(omitUser{}), IsNil)
+	c.Assert(validator.Check(omitUser{}), IsNil)
+
+	zero, young := 0, 17
+	errs, ok := validator.Validate(omitUser{
+		Website: "http://example.com",
+		Age:     &young,
+		Tags:    []string{"ab"},
+		Labels:  map[string]string{"a": "", "b": ""},
+		Count:   11,
+	}).(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 6)
+	c.Assert(errs["Website"], HasError, validator.ErrRegexp)
+	c.Assert(errs["Age"], HasError, validator.ErrMin)
+	c.Assert(errs["Tags"], HasError, validator.ErrMin)
+	c.Assert(errs["Tags[0]"], HasError, validator.ErrMin)
+	c.Assert(errs["Labels"], HasError, validator.ErrMax)
+	// rules before omitempty always run
+	c.Assert(errs["Count"], HasError, validator.ErrMax)
+	c.Assert(errs["Count"], Not(HasError), validator.ErrMin)
+
+	// pointers to zero values are empty, as for nonzero
+	c.Assert(validator.Validate(omitUser{Age: &zero, Count: 3}), DeepEquals,
+		validator.ErrorMap{"Count": validator.ErrorArray{validator.ErrMin}})
+	c.Assert(validator.WithFieldErrors(true).Validate(omitUser{Age: &zero, Count: 3}), DeepEquals,
+		validator.ErrorMap{"Count": validator.ErrorArray{&validator.FieldError{
+			Path: "Count", Field: "Count", Name: "Count", JSONName: "Count", Rule: "min", Param: "5", Value: 3, Err: validator.ErrMin,
+		}}})
+
+	c.Assert(validator.Valid("", "omitempty,regexp=^x"), IsNil)
+	c.Assert(validator.Valid("y", "omitempty,regexp=^x"), HasError, validator.ErrRegexp)
+	c.Assert(validator.Valid([]int{}, "omitempty,dive,min=1"), IsNil)
+	c.Assert(validator.Valid([]int{0}, "omitempty,dive,min=1"), NotNil)
+	c.Assert(validator.Valid(nil, "omitempty,nonzero"), IsNil)
+
+	err := validator.Valid("", "omitempty=1")
+	c.Assert(errors.Is(err, validator.ErrBadParameter), Equals, true)
+	err = validator.Valid("", "(omitempty|len=1)")
+	c.Assert(errors.Is(err, validator.ErrUnknownTag), Equals, true)
+
+	s, err := validator.JSONSchema(omitUser{})
+	c.Assert(err, IsNil)
+	props := map[string]string{
+		"Website": `{"type":"string","allOf":[{"anyOf":[{"maxLength":0},{"pattern":"^https://"}]}]}`,
+		"Tags":    `{"type":["array","null"],"items":{"type":"string","minLength":3},"allOf":[{"anyOf":[{"maxItems":0},{"minItems":2}]}]}`,
+		"Count":   `{"type":"integer","allOf":[{"anyOf":[{"enum":[0]},{"minimum":5}]}],"maximum":10}`,
+		// the schema does not describe empty values of the struct
+		"Home": `{"anyOf":[{"$ref":"#/$defs/schemaAddress"},{"type":"null"}]}`,
+	}
+	for name, want := range props {
+		b, err := json.Marshal(s.Defs["omitUser"].Properties[name])
+		c.Assert(err, IsNil)
+		c.Check(string(b), Equals, want, Commentf("property %s", name))
+	}
+	c.Assert(s.Defs["omitUser"].Required, IsNil)
+	c.Assert(validator.ValidateJSON(map[string]interface{}{"Website": "", "Count": json.Number("0")}, s), IsNil)
+	c.Assert(validator.ValidateJSON(map[string]interface{}{"Website": "ftp://", "Count": json.Number("3")}, s), NotNil)
+
+	tags, err := validator.Field(validator.Rules[omitUser](),
+		func(u *omitUser) *string { return &u.Website }, validator.OmitEmpty(), validator.Regexp("^https://")).
+		Tags()
+	c.Assert(err, IsNil)
+	c.Assert(tags["Website"], Equals, "omitempty,regexp=^https://")
+}
+
+type msgUser struct {
+	Name string   `validate:"nonzero,msg={field} is required,code=E100,max=5,msg={field} is longer than {param} characters: {value}"`
+	Age  int      `validate:"msg=age must be between 18 and 130,code=AGE,min=18,max=130"`
+	Zip  string   `validate:"(len=0|len=5),msg=invalid zip code"`
+	Tags []string `validate:"dive,msg={path} is empty,nonzero"`
+	Note string   `validate:"max=3,msg=too long\\, sorry"`
+}
+
+func (ms *MySuite) TestMessages(c *C) {
+	c.Assert(validator.Check(msgUser{}), IsNil)
+	errs, ok := validator.Validate(msgUser{Age: 10, Zip: "12", Tags: []string{"a", ""}, Note: "abcd"}).(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 5)
+	for path, want := range map[string]string{
+		"Name":    "Name is required",
+		"Age":     "age must be between 18 and 130",
+		"Zip":     "invalid zip code",
+		"Tags[1]": "Tags[1] is empty",
+		"Note":    "too long, sorry",
+	} {
+		c.Check(errs[path].Error(), Equals, want, Commentf("path %s", path))
+	}
+	fe := errs["Name"][0].(*validator.FieldError)
+	c.Assert(fe.Code, Equals, "E100")
+	c.Assert(fe.Err, Equals, validator.ErrZeroValue)
+	fe = errs["Age"][0].(*validator.FieldError)
+	c.Assert(fe.Code, Equals, "AGE")
+	c.Assert(errors.Is(fe, validator.ErrMin), Equals, true)
+	c.Assert(errs["Zip"], WrapsError, validator.ErrLen)
+
+	errs, ok = validator.Validate(msgUser{Name: "Gandalf", Age: 140}).(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	fe = errs["Name"][0].(*validator.FieldError)
+	c.Assert(fe.Message, Equals, "Name is longer than 5 characters: Gandalf")
+	c.Assert(fe.Code, Equals, "")
+	c.Assert(errs["Age"][0].(*validator.FieldError).Code, Equals, "AGE")
+	c.Assert(errs.Error(), Matches, ".*Age: age must be between 18 and 130.*")
+
+	c.Assert(validator.Valid("", "nonzero,msg={rule} failed on {field}{path}").Error(), Equals, "nonzero failed on ")
+	err := validator.Valid("", "(msg=x|len=1)")
+	c.Assert(errors.Is(err, validator.ErrUnknownTag), Equals, true)
+
+	// messages given first only apply to the rules of their own tag
+	v := validator.NewValidator()
+	c.Assert(v.MergeRules(msgUser{}, map[string]string{"Note": "msg=registered,regexp=^a"}), IsNil)
+	errs, ok = v.Validate(msgUser{Name: "a", Age: 20, Note: "bcde"}).(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs["Note"].Error(), Equals, "too long, sorry, registered")
+
+	tags, err := validator.Field(validator.Rules[msgUser](), func(u *msgUser) *int { return &u.Age },
+		validator.Min(18).WithMessage("at least {param}, please").WithCode("MIN"), validator.Max(130)).
+		Tags()
+	c.Assert(err, IsNil)
+	c.Assert(tags["Age"], Equals, `min=18,msg=at least {param}\, please,code=MIN,max=130`)
+	c.Assert(v.RegisterRules(msgUser{}, tags), IsNil)
+	errs, ok = v.Validate(msgUser{Name: "a", Age: 1}).(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs["Age"].Error(), Equals, "at least 18, please")
+}
+
+type i18nUser struct {
+	Name  string   `validate:"nonzero"`
+	Age   int      `validate:"min=18"`
+	Tags  []string `validate:"min=1"`
+	Roles []string `validate:"min=2"`
+	Zip   string   `validate:"(len=0|len=5)"`
+	Note  string   `validate:"max=3,msg=too long"`
+}
+
+func (ms *MySuite) TestTranslate(c *C) {
+	u := i18nUser{Age: 10, Zip: "12", Note: "abcd"}
+	err := validator.WithFieldErrors(true).Validate(u)
+	for locale, want := range map[string]map[string]string{
+		"en": {
+			"Name":  "Name is required",
+			"Age":   "Age must be at least 18",
+			"Tags":  "Tags must contain at least 1 item",
+			"Roles": "Roles must contain at least 2 items",
+			"Zip":   "Zip must be 0 characters long or Zip must be 5 characters long",
+		},
+		"nl-BE": {
+			"Name":  "Name is verplicht",
+			"Tags":  "Tags moet minstens 1 element bevatten",
+			"Roles": "Roles moet minstens 2 elementen bevatten",
+		},
+		"de": {
+			"Age":   "Age muss mindestens 18 sein",
+			"Roles": "Roles muss mindestens 2 Elemente enthalten",
+			"Zip":   "Zip muss 0 Zeichen lang sein oder Zip muss 5 Zeichen lang sein",
+		},
+		"fr": {
+			"Name": "Name est obligatoire",
+			"Zip":  "Zip doit contenir 0 caractère ou Zip doit contenir 5 caractères",
+		},
+	} {
+		errs, ok := validator.Translate(err, locale).(validator.ErrorMap)
+		c.Assert(ok, Equals, true)
+		for path, msg := range want {
+			c.Check(errs[path].Error(), Equals, msg, Commentf("%s %s", locale, path))
+		}
+		c.Assert(errs["Note"].Error(), Equals, "too long")
+		c.Assert(errs["Age"], WrapsError, validator.ErrMin)
+	}
+	// the errors given are left unchanged
+	c.Assert(err.(validator.ErrorMap)["Name"].Error(), Equals, "zero value")
+	c.Assert(validator.Translate(err, "ja"), DeepEquals, err)
+
+	// without FieldErrors, the parameters of the rules are unknown
+	errs, ok := validator.Translate(validator.Validate(u), "en").(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs["Name"].Error(), Equals, "Name is required")
+	c.Assert(errs["Age"], HasError, validator.ErrMin)
+
+	errs, ok = validator.ValidateContext(validator.ContextWithLocale(context.Background(), "fr"), u).(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs["Age"].Error(), Equals, "Age doit être supérieur ou égal à 18")
+	c.Assert(validator.LocaleFromContext(context.Background()), Equals, "")
+
+	ctx := validator.ContextWithLocale(context.Background(), "de")
+	c.Assert(validator.ValidContext(ctx, "", "nonzero").Error(), Equals, "Wert ist erforderlich")
+	c.Assert(validator.ValidContext(ctx, "ab", "!regexp=^a").Error(), Equals, "Wert ist nicht erlaubt")
+	c.Assert(validator.ValidContext(ctx, 1, "regexp=^a").Error(), Equals, "Wert kann nicht mit regexp geprüft werden")
+
+	// the translator comes before the bundled catalogs
+	v := validator.WithFieldErrors(true).WithTranslator(validator.TranslatorFunc(func(t validator.Translation) (string, bool) {
+		if t.Locale == "en" && errors.Is(t.Err, validator.ErrZeroValue) {
+			return "please fill in " + t.Label, true
+		}
+		return "", false
+	}))
+	errs, ok = v.Translate(v.Validate(u), "en").(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs["Name"].Error(), Equals, "please fill in Name")
+	c.Assert(errs["Age"].Error(), Equals, "Age must be at least 18")
+
+	cat := validator.DefaultCatalog()
+	cat["en"]["min"] = "{label} is too small, {value} < {param}"
+	errs, ok = v.WithPrintJSON(true).WithTranslator(cat).Translate(err, "en").(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs["Age"].Error(), Equals, "Age is too small, 10 < 18")
+	c.Assert(validator.DefaultCatalog()["en"]["min"], Equals, "{label} must be at least {param}")
+
+	// every locale covers all builtin rules
+	for locale, msgs := range validator.DefaultCatalog() {
+		for _, rule := range []string{"nonzero", "nonnil", "len", "min", "max", "regexp",
+			"eqfield", "nefield", "gtfield", "gtefield", "ltfield", "ltefield",
+			"required_if", "required_unless", "required_with", "required_without"} {
+			_, found := msgs[rule]
+			c.Check(found, Equals, true, Commentf("%s %s", locale, rule))
+		}
+	}
+}
+
+type labelUser struct {
+	DOB  string   `json:"dob" label:"Date of birth" validate:"nonzero"`
+	Name string   `validate:"nonzero,msg={label} is missing"`
+	Nick string   `label:"Nickname" title:"Alias" validate:"min=2,msg={label} ({field}) is too short"`
+	Zip  string   `label:"Zip code" validate:"(len=0|len=5)"`
+	Tags []string `label:"Tag" validate:"dive,nonzero"`
+}
+
+func (ms *MySuite) TestLabels(c *C) {
+	u := labelUser{Nick: "a", Zip: "1", Tags: []string{""}}
+	fev := validator.WithFieldErrors(true)
+	errs, ok := fev.Validate(u).(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 5)
+	fe := errs["DOB"][0].(*validator.FieldError)
+	c.Assert(fe.Label, Equals, "Date of birth")
+	c.Assert(fe.Error(), Equals, "zero value")
+	c.Assert(errs["Name"][0].(*validator.FieldError).Label, Equals, "")
+	c.Assert(errs["Name"].Error(), Equals, "Name is missing")
+	c.Assert(errs["Nick"].Error(), Equals, "Nickname (Nick) is too short")
+	c.Assert(errs["Tags[0]"][0].(*validator.FieldError).Label, Equals, "Tag")
+	alt := errs["Zip"][0].(*validator.FieldError).Err.(validator.AlternativesError)
+	c.Assert(alt[0].(*validator.FieldError).Label, Equals, "Zip code")
+
+	errs, ok = validator.Translate(errs, "en").(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs["DOB"].Error(), Equals, "Date of birth is required")
+	c.Assert(errs["Zip"].Error(), Equals, "Zip code must be 0 characters long or Zip code must be 5 characters long")
+	c.Assert(errs["Tags[0]"].Error(), Equals, "Tag is required")
+
+	// keys do not depend on labels
+	errs, ok = fev.WithPrintJSON(true).Validate(u).(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs["dob"][0].(*validator.FieldError).Label, Equals, "Date of birth")
+
+	v := fev.WithLabelFunc(validator.LabelTag("title"))
+	errs, ok = v.Validate(u).(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs["DOB"][0].(*validator.FieldError).Label, Equals, "")
+	c.Assert(errs["Nick"].Error(), Equals, "Alias (Nick) is too short")
+
+	v = fev.WithLabelFunc(func(f reflect.StructField) string {
+		return strings.ToLower(f.Name)
+	})
+	errs, ok = v.Translate(v.Validate(u), "nl").(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs["DOB"].Error(), Equals, "dob is verplicht")
+}
+
+type nameAddress struct {
+	City string `json:"city" yaml:"town" xml:"urn:x place,attr" form:"c" validate:"nonzero"`
+}
+
+type nameBase struct {
+	ID int `json:"id" yaml:"ident" validate:"min=1"`
+}
+
+type nameUser struct {
+	nameBase `yaml:",inline"`
+	FullName string        `json:"full_name,omitempty" yaml:"full-name" form:"fullName" protobuf:"bytes,1,opt,name=full_name,json=fullName,proto3" validate:"nonzero"`
+	Homes    []nameAddress `json:"homes" xml:"home" form:"homes"`
+	Skipped  string        `json:"-" validate:"nonzero"`
+	Kind     interface{}   `protobuf_oneof:"kind" validate:"nonnil"`
+}
+
+func (ms *MySuite) TestNameFuncs(c *C) {
+	u := nameUser{Homes: []nameAddress{{}}}
+	for _, t := range []struct {
+		v    *validator.Validator
+		keys []string
+	}{
+		{validator.NewValidator(), []string{"nameBase.ID", "FullName", "Homes[0].City", "Skipped", "Kind"}},
+		{validator.WithNameFunc(validator.JSONFieldName), []string{"nameBase.id", "full_name", "homes[0].city", "Skipped", "Kind"}},
+		// json:"-" fields have no name with SetPrintJSON
+		{validator.WithPrintJSON(true), []string{"nameBase.id", "full_name", "homes[0].city", "", "Kind"}},
+		{validator.WithNameFunc(validator.YAMLFieldName), []string{"nameBase.ident", "full-name", "homes[0].town", "skipped", "kind"}},
+		{validator.WithNameFunc(validator.XMLFieldName), []string{"nameBase.ID", "FullName", "home[0].place", "Skipped", "Kind"}},
+		{validator.WithNameFunc(validator.FormFieldName), []string{"nameBase.ID", "fullName", "homes[0].c", "Skipped", "Kind"}},
+		{validator.WithNameFunc(validator.NameTag("form")), []string{"nameBase.ID", "fullName", "homes[0].c", "Skipped", "Kind"}},
+		{validator.WithNameFunc(validator.ProtobufFieldName), []string{"nameBase.ID", "fullName", "Homes[0].City", "Skipped", "kind"}},
+		// embedded structs given no name are flattened when asked for
+		{validator.WithFlattenEmbedded(true).WithPrintJSON(true), []string{"nameBase.id", "full_name", "homes[0].city", "", "Kind"}},
+		{validator.WithFlattenEmbedded(true).WithNameFunc(validator.JSONFieldName), []string{"id", "full_name", "homes[0].city", "Skipped", "Kind"}},
+		{validator.WithFlattenEmbedded(true).WithNameFunc(validator.YAMLFieldName), []string{"ident", "full-name", "homes[0].town", "skipped", "kind"}},
+	} {
+		errs, ok := t.v.Validate(u).(validator.ErrorMap)
+		c.Assert(ok, Equals, true)
+		keys := make([]string, 0, len(errs))
+		for k := range errs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		sort.Strings(t.keys)
+		c.Check(keys, DeepEquals, t.keys)
+	}
+
+	v := validator.WithFieldErrors(true).WithNameFunc(validator.ProtobufFieldName)
+	errs, ok := v.Validate(u).(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	fe := errs["fullName"][0].(*validator.FieldError)
+	c.Assert(fe.Path, Equals, "fullName")
+	c.Assert(fe.Name, Equals, "fullName")
+	c.Assert(fe.Field, Equals, "FullName")
+	c.Assert(fe.JSONName, Equals, "full_name")
+	errs, ok = v.Translate(errs, "en").(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs["fullName"].Error(), Equals, "fullName is required")
+
+	// the default names are restored
+	v.SetPrintJSON(false)
+	_, ok = v.Validate(u).(validator.ErrorMap)["FullName"]
+	c.Assert(ok, Equals, true)
+}
+
+type omitHome struct {
+	Home schemaAddress  `validate:"omitempty"`
+	Work *schemaAddress `validate:"omitempty"`
+}
+
+func (ms *MySuite) TestOmitEmptyStruct(c *C) {
+	// the fields of empty structs are not validated
+	c.Assert(validator.Validate(omitHome{}), IsNil)
+	c.Assert(validator.Validate(omitHome{Work: &schemaAddress{}}), IsNil)
+	c.Assert(validator.Valid(schemaAddress{}, "omitempty"), IsNil)
+
+	err := validator.Validate(omitHome{Home: schemaAddress{Zip: "1"}, Work: &schemaAddress{Street: "Main"}})
+	errs, ok := err.(validator.ErrorMap)
+	c.Assert(ok, Equals, true)
+	c.Assert(errs, HasLen, 3)
+	c.Assert(errs["Home.Street"], HasError, validator.ErrZeroValue)
+	c.Assert(errs["Home.Zip"], HasError, validator.ErrLen)
+	c.Assert(errs["Work.Zip"], HasError, validator.ErrLen)
+}
+
+const partnerSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["id", "customer", "items"],
+	"properties": {
+		"id": {"type": "string", "pattern": "^ORD-[0-9]+$"},
+		"status": {"enum": ["new", "paid", 3]},
+		"customer": {
+			"type": "object",
+			"required": ["email"],
+			"properties": {
+				"email": {"type": "string", "minLength": 3, "maxLength": 20},
+				"age": {"type": "integer", "minimum": 18}
+			}
+		},
+		"items": {
+			"type": "array",
+			"minItems": 1,
+			"items": {
+				"type": "object",
+				"properties": {
+					"sku": {"type": "string"},
+					"qty": {"type": "number", "minimum": 1, "maximum": 99}
+				},
+				"x-partner": true
+			}
+		},
+		"notes": false
+	}
+}`
+
+func (ms *MySuite) TestValidateJSON(c *C) {
+	var schema validator.Schema
+	c.Assert(json.Unmarshal([]byte(partnerSchema), &schema), IsNil)
+	c.Assert(schema.Properties["items"].Items.Extra, DeepEquals, map[string]interface{}{"x-partner": true})
+
+	decode := func(doc string) interface{} {
+		var v interface{}
+		c.Assert(json.Unmarshal([]byte(doc), &v), IsNil)
+		return v
+	}
+	valid := decode(`{"id": "ORD-1", "status": 3, "customer": {"email": "a@b.c", "age": 30},
+		"items": [{"sku": "x", "qty": 2}]}`)
+	c.Assert(validator.ValidateJSON(valid, &schema), IsNil)
+
+	invalid := decode(`{"id": "order", "status": "lost", "customer": {"age": 12.5},
+		"items": [{"qty": 0}, {"sku": 7, "qty": 100}], "notes": "n"}`)
+	err := validator.ValidateJSON(invalid, &schema)
+	c.Assert(err, NotNil)
+	errs := err.(validator.ErrorMap)
+	c.Assert(errs, HasLen, 8)
+	c.Assert(errs["id"], HasError, validator.ErrRegexp)
+	c.Assert(errs["status"], WrapsError, validator.ErrInvalid)
+	c.Assert(errs["customer.email"], HasError, validator.ErrZeroValue)
+	c.Assert(errs["customer.age"], HasError, validator.ErrType)
+	c.Assert(errs["items[0].qty"], HasError, validator.ErrMin)
+	c.Assert(errs["items[1].sku"], HasError, validator.ErrType)
+	c.Assert(errs["items[1].qty"], HasError, validator.ErrMax)
+	c.Assert(errs["notes"], HasError, validator.ErrNot)
+
+	errs = validator.WithFieldErrors(true).ValidateJSON(invalid, &schema).(validator.ErrorMap)
+	var fe *validator.FieldError
+	c.Assert(errors.As(errs["items[1].qty"][0], &fe), Equals, true)
+	c.Assert(fe.Path, Equals, "items[1].qty")
+	c.Assert(fe.Field, Equals, "qty")
+	c.Assert(fe.Rule, Equals, "maximum")
+	c.Assert(fe.Param, Equals, "99")
+	c.Assert(fe.Value, Equals, float64(100))
+
+	err = validator.ValidateJSON(decode(`{"id": "ORD-1", "customer": {"email": "a"}, "items": []}`), &schema)
+	c.Assert(err, NotNil)
+	errs = err.(validator.ErrorMap)
+	c.Assert(errs, HasLen, 2)
+	c.Assert(errs["customer.email"], HasError, validator.ErrMin)
+	c.Assert(errs["items"], HasError, validator.ErrMin)
+
+	// the errors of the value itself have an empty path
+	err = validator.ValidateJSON(decode(`[]`), &schema)
+	c.Assert(err, NotNil)
+	c.Assert(err.(validator.ErrorMap)[""], HasError, validator.ErrType)
+
+	// numbers decoded with UseNumber
+	d := json.NewDecoder(strings.NewReader(`{"id": "ORD-2", "customer": {"email": "abc", "age": 17},
+		"items": [{"qty": 1.5}]}`))
+	d.UseNumber()
+	var v interface{}
+	c.Assert(d.Decode(&v), IsNil)
+	err = validator.ValidateJSON(v, &schema)
+	c.Assert(err, NotNil)
+	errs = err.(validator.ErrorMap)
+	c.Assert(errs, HasLen, 1)
+	c.Assert(errs["customer.age"], HasError, validator.ErrMin)
+
+	// schemas written by JSONSchema, with their references
+	v2 := validator.NewValidator()
+	v2.SetValidationFunc("even", func(interface{}, string) error { return nil })
+	generated, err := v2.JSONSchema(schemaUser{})
+	c.Assert(err, IsNil)
+	doc, err := json.Marshal(generated)
+	c.Assert(err, IsNil)
+	var userSchema validator.Schema
+	c.Assert(json.Unmarshal(doc, &userSchema), IsNil)
+	age := 12
+	user := schemaUser{
+		Name:  "Robert",
+		Score: 1,
+		Age:   &age,
+		Home:  &schemaAddress{Zip: "1234a"},
+		Data:  []byte("x"),
+		Friends: []*schemaUser{
+			nil,
+			{Name: strings.Repeat("x", 41), Score: 1, Home: &schemaAddress{Street: "Main"}, Data: []byte("y")},
+		},
+	}
+	doc, err = json.Marshal(user)
+	c.Assert(err, IsNil)
+	err = validator.ValidateJSON(decode(string(doc)), &userSchema)
+	c.Assert(err, NotNil)
+	errs = err.(validator.ErrorMap)
+	c.Assert(errs["id"], HasError, validator.ErrMin)
+	c.Assert(errs["age"], HasError, validator.ErrMin)
+	c.Assert(errs["home.street"], HasError, validator.ErrMin)
+	c.Assert(errs["home.zip"], HasError, validator.ErrRegexp)
+	c.Assert(errs["friends[1].name"], HasError, validator.ErrMax)
+	c.Assert(errs["friends[1].id"], HasError, validator.ErrMin)
+	c.Assert(errs, HasLen, 6)
+
+	// the names and values of map entries have their own paths
+	var mapSchema validator.Schema
+	c.Assert(json.Unmarshal([]byte(`{"type": "object",
+		"properties": {"labels": {"propertyNames": {"minLength": 2}, "additionalProperties": {"type": "integer"}}}}`),
+		&mapSchema), IsNil)
+	err = validator.ValidateJSON(decode(`{"labels": {"a": "x", "bc": 1}}`), &mapSchema)
+	c.Assert(err, NotNil)
+	errs = err.(validator.ErrorMap)
+	c.Assert(errs, HasLen, 2)
+	c.Assert(errs["labels[a](key)"], HasError, validator.ErrMin)
+	c.Assert(errs["labels[a](value)"], HasError, validator.ErrType)
+
+	// the errors of enum and anyOf hold those of each alternative
+	var altSchema validator.Schema
+	c.Assert(json.Unmarshal([]byte(`{"properties": {
+		"size": {"enum": ["S", "M"]},
+		"code": {"anyOf": [{"type": "string", "minLength": 3}, {"type": "string", "pattern": "^[0-9]+$"}]}}}`),
+		&altSchema), IsNil)
+	errs = validator.ValidateJSON(decode(`{"size": "XL", "code": "ab"}`), &altSchema).(validator.ErrorMap)
+	c.Assert(errs["size"], HasLen, 1)
+	c.Assert(errs["size"][0].Error(), Equals, `const="S": invalid value or const="M": invalid value`)
+	c.Assert(errs["code"], HasLen, 1)
+	c.Assert(errs["code"][0].Error(), Equals, "minLength=3: less than min or pattern=^[0-9]+$: regular expression mismatch")
+	c.Assert(errs["code"], WrapsError, validator.ErrMin)
+	c.Assert(errs["code"], WrapsError, validator.ErrRegexp)
+
+	// unusable keywords
+	var badSchema validator.Schema
+	c.Assert(json.Unmarshal([]byte(`{"pattern": "(", "$ref": "#/$defs/missing"}`), &badSchema), IsNil)
+	err = validator.ValidateJSON("x", &badSchema)
+	c.Assert(err, NotNil)
+	c.Assert(err.(validator.ErrorMap)[""], HasLen, 2)
+	c.Assert(err.(validator.ErrorMap)[""], HasError, validator.ErrBadParameter)
+}
+
+type hasErrorChecker struct {
+	*CheckerInfo
+}
+
+func (c *hasErrorChecker) Check(params []interface{}, names []string) (bool, string) {
+	var (
+		ok    bool
+		slice []error
+		value error
+	)
+	slice, ok = params[0].(validator.ErrorArray)
+	if !ok {
+		return false, "First parameter is not an Errorarray"
+	}
+	value, ok = params[1].(error)
+	if !ok {
+		return false, "Second parameter is not an error"
+	}
+
+	for _, v := range slice {
+		if v == value {
+			return true, ""
+		}
+	}
+	return false, ""
+}
+
+func (c *hasErrorChecker) Info() *CheckerInfo {
+	return c.CheckerInfo
+}
+
+var HasError = &hasErrorChecker{&CheckerInfo{Name: "HasError", Params: []string{"HasError", "expected to contain"}}}
+
+type wrapsErrorChecker struct {
+	*CheckerInfo
+}
+
+func (c *wrapsErrorChecker) Check(params []interface{}, names []string) (bool, string) {
+	slice, ok := params[0].(validator.ErrorArray)
+	if !ok {
+		return false, "First parameter is not an Errorarray"
+	}
+	value, ok := params[1].(error)
+	if !ok {
+		return false, "Second parameter is not an error"
+	}
+
+	for _, v := range slice {
+		if errors.Is(v, value) {
+			return true, ""
+		}
+	}
+	return false, ""
+}
+
+func (c *wrapsErrorChecker) Info() *CheckerInfo {
+	return c.CheckerInfo
+}
+
+// WrapsError checks that an ErrorArray holds an error wrapping the given
+// one, such as a *FieldError or a *TagError.
+var WrapsError = &wrapsErrorChecker{&CheckerInfo{Name: "WrapsError", Params: []string{"WrapsError", "expected to wrap"}}}
+
+type benchAddress struct {
+	Street string `validate:"nonzero"`
+	Zip    string `validate:"len=5,regexp=^[0-9]+$"`
+}
+
+type benchUser struct {
+	Username  string `validate:"min=3,max=40,regexp=^[a-zA-Z]*$"`
+	Name      string `validate:"nonzero"`
+	Age       int    `validate:"min=18"`
+	Password  string `validate:"min=8"`
+	Email     string
+	Address   benchAddress
+	Addresses []benchAddress `validate:"max=10"`
+	Tags      []string       `validate:"max=5"`
+}
+
+func newBenchUser() benchUser {
+	return benchUser{
+		Username:  "someone",
+		Name:      "Some One",
+		Age:       42,
+		Password:  "secret123",
+		Address:   benchAddress{Street: "Main St", Zip: "12345"},
+		Addresses: []benchAddress{{Street: "A", Zip: "11111"}, {Street: "B", Zip: "22222"}},
+		Tags:      []string{"a", "b"},
+	}
+}
+
+func BenchmarkValidate(b *testing.B) {
+	u := newBenchUser()
+	v := validator.NewValidator()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := v.Validate(u); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkValidateInvalid(b *testing.B) {
+	u := newBenchUser()
+	u.Age = 12
+	u.Addresses[1].Zip = "abc"
+	v := validator.NewValidator()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := v.Validate(u); err == nil {
+			b.Fatal("expected an error")
+		}
+	}
+}
+
+func BenchmarkValid(b *testing.B) {
+	v := validator.NewValidator()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := v.Valid("someone", "min=3,max=40,regexp=^[a-zA-Z]*$"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}